@@ -0,0 +1,329 @@
+// Package cassette provides a core.HTTPClient recording the API traffic to a cassette file,
+// and replaying it later without network access
+//
+// Credentials and personal data are never written: the X-API-KEY and Authorization headers are redacted, and
+// so are the JSON fields whose name ends with email, password, token, secret, callback_url or payout_address,
+// e.g. customer_email or ipn_callback_url
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/rotisserie/eris"
+)
+
+// Mode tells if a recorder records new interactions or replays recorded ones
+type Mode int
+
+const (
+	// Replay serves the interactions of an existing cassette, without network access
+	Replay Mode = iota
+	// Record sends the requests to the real client and records them, the cassette being written on Stop
+	Record
+)
+
+// Redacted replaces the redacted values
+const Redacted = "REDACTED"
+
+var (
+	redactedHeaders = []string{"X-API-KEY", "Authorization"}
+	redactedFields  = []string{"email", "password", "token", "secret", "callback_url", "payout_address"}
+)
+
+// Request is a recorded request
+type Request struct {
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Query   string      `json:"query,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Interaction is a request and its response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is the content of a cassette file
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// UnrecordedError is returned when replaying a request missing from the cassette
+type UnrecordedError struct {
+	Cassette string
+	Request  Request
+}
+
+func (e *UnrecordedError) Error() string {
+	r := e.Request.Method + " " + e.Request.Path
+	if e.Request.Query != "" {
+		r += "?" + e.Request.Query
+	}
+	if e.Request.Body != "" {
+		r += " " + e.Request.Body
+	}
+	return fmt.Sprintf("cassette %s: unrecorded request %s", e.Cassette, r)
+}
+
+// Recorder is a core.HTTPClient recording or replaying a cassette
+type Recorder struct {
+	path   string
+	mode   Mode
+	client core.HTTPClient
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+var _ core.HTTPClient = (*Recorder)(nil)
+
+// New returns a recorder for the cassette file at path
+// In Record mode, requests are sent with client, core.NewHTTPClient() being used when nil
+// In Replay mode, the cassette file must exist
+func New(path string, mode Mode, client core.HTTPClient) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode, client: client}
+
+	switch mode {
+	case Record:
+		if r.client == nil {
+			r.client = core.NewHTTPClient()
+		}
+	case Replay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, eris.Wrap(err, "cassette")
+		}
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, eris.Wrapf(err, "cassette %s", path)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	default:
+		return nil, eris.Errorf("cassette: unknown mode %d", mode)
+	}
+
+	return r, nil
+}
+
+// Do records or replays the request
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, eris.Wrap(err, "cassette")
+	}
+	rec := newRequest(req, body)
+
+	if r.mode == Replay {
+		return r.replay(req, rec)
+	}
+	return r.record(req, rec)
+}
+
+func (r *Recorder) replay(req *http.Request, rec Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Identical requests are replayed in the recorded order, e.g. when polling a status
+	for i, it := range r.cassette.Interactions {
+		if r.used[i] || !it.Request.matches(rec) {
+			continue
+		}
+		r.used[i] = true
+		return it.Response.http(req), nil
+	}
+
+	return nil, &UnrecordedError{Cassette: r.path, Request: rec}
+}
+
+func (r *Recorder) record(req *http.Request, rec Request) (*http.Response, error) {
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, eris.Wrap(err, "cassette")
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Request: rec,
+		Response: Response{
+			StatusCode: res.StatusCode,
+			Headers:    redactHeaders(res.Header),
+			Body:       normalizeBody(body),
+		},
+	})
+	r.mu.Unlock()
+
+	return res, nil
+}
+
+// Stop writes the recorded interactions to the cassette file in Record mode
+// In Replay mode, an error lists the interactions which were not replayed
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.mode == Replay {
+		var unused []string
+		for i, it := range r.cassette.Interactions {
+			if !r.used[i] {
+				unused = append(unused, it.Request.Method+" "+it.Request.Path)
+			}
+		}
+		if len(unused) > 0 {
+			return eris.Errorf("cassette %s: %d interactions not replayed: %s", r.path, len(unused), strings.Join(unused, ", "))
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(&r.cassette, "", "  ")
+	if err != nil {
+		return eris.Wrap(err, "cassette")
+	}
+	return eris.Wrap(os.WriteFile(r.path, append(data, '\n'), 0o644), "cassette")
+}
+
+// Interactions returns the recorded or loaded interactions
+func (r *Recorder) Interactions() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*Interaction{}, r.cassette.Interactions...)
+}
+
+func (rq Request) matches(o Request) bool {
+	return rq.Method == o.Method && rq.Path == o.Path && rq.Query == o.Query && rq.Body == o.Body
+}
+
+func (rs Response) http(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rs.StatusCode, http.StatusText(rs.StatusCode)),
+		StatusCode:    rs.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rs.Headers.Clone(),
+		Body:          io.NopCloser(strings.NewReader(rs.Body)),
+		ContentLength: int64(len(rs.Body)),
+		Request:       req,
+	}
+}
+
+// readBody reads the request body, restoring it to be sent afterwards
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// newRequest returns the redacted and normalized form of a request, the query parameters being sorted
+func newRequest(req *http.Request, body []byte) Request {
+	return Request{
+		Method:  req.Method,
+		Path:    req.URL.Path,
+		Query:   req.URL.Query().Encode(),
+		Headers: redactHeaders(req.Header),
+		Body:    normalizeBody(body),
+	}
+}
+
+func redactHeaders(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	h = h.Clone()
+	for _, k := range redactedHeaders {
+		if h.Get(k) != "" {
+			h.Set(k, Redacted)
+		}
+	}
+	return h
+}
+
+// normalizeBody returns a JSON body with sorted keys and redacted credentials, other bodies being kept as is
+func normalizeBody(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil || d.More() {
+		return string(body)
+	}
+
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(redact(v)); err != nil {
+		return string(body)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if s, ok := e.(string); ok && s != "" && redactedField(k) {
+				t[k] = Redacted
+				continue
+			}
+			t[k] = redact(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = redact(e)
+		}
+	}
+	return v
+}
+
+// redactedField tells if the JSON field k holds a value which must not be written, its name ending with
+// one of the redacted fields
+func redactedField(k string) bool {
+	k = strings.ToLower(k)
+	for _, f := range redactedFields {
+		if strings.HasSuffix(k, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnrecorded tells if err comes from a request missing from the cassette
+func IsUnrecorded(err error) bool {
+	var u *UnrecordedError
+	return errors.As(err, &u)
+}
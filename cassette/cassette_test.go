@@ -0,0 +1,115 @@
+package cassette_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CIDgravity/go-nowpayments/cassette"
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/nowpaymentstest"
+	"github.com/CIDgravity/go-nowpayments/payments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReplay(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	path := filepath.Join(t.TempDir(), "payments.json")
+
+	srv := nowpaymentstest.NewServer()
+	require.NoError(srv.Use())
+
+	rec, err := cassette.New(path, cassette.Record, nil)
+	require.NoError(err)
+	core.UseClient(rec)
+
+	pa := &payments.PaymentArgs{
+		PaymentAmount: payments.PaymentAmount{PriceAmount: 100, PriceCurrency: "usd", PayCurrency: "btc", OrderID: "order-1"},
+	}
+	p, err := payments.New(pa)
+	require.NoError(err)
+	ps, err := payments.List(&payments.ListOption{Limit: 5, OrderBy: "desc"})
+	require.NoError(err)
+	require.NoError(rec.Stop())
+	srv.Close()
+
+	data, err := os.ReadFile(path)
+	require.NoError(err)
+	assert.NotContains(string(data), nowpaymentstest.APIKey)
+	assert.NotContains(string(data), nowpaymentstest.Login)
+	assert.NotContains(string(data), nowpaymentstest.Password)
+	assert.Contains(string(data), cassette.Redacted)
+
+	// Replay without the server
+	rep, err := cassette.New(path, cassette.Replay, nil)
+	require.NoError(err)
+	core.UseClient(rep)
+
+	got, err := payments.New(pa)
+	require.NoError(err)
+	assert.Equal(p, got)
+	gotList, err := payments.List(&payments.ListOption{OrderBy: "desc", Limit: 5})
+	require.NoError(err)
+	assert.Equal(ps, gotList)
+	assert.NoError(rep.Stop())
+
+	_, err = payments.Status(p.ID.String())
+	assert.True(cassette.IsUnrecorded(err), "%v", err)
+
+	rep, err = cassette.New(path, cassette.Replay, nil)
+	require.NoError(err)
+	core.UseClient(rep)
+	_, err = payments.New(pa)
+	require.NoError(err)
+	assert.Error(rep.Stop(), "list interactions not replayed")
+
+	_, err = cassette.New(filepath.Join(t.TempDir(), "missing.json"), cassette.Replay, nil)
+	assert.Error(err)
+}
+
+func TestRecordRedactsInvoiceFields(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	path := filepath.Join(t.TempDir(), "invoice.json")
+
+	srv := nowpaymentstest.NewServer()
+	require.NoError(srv.Use())
+	defer srv.Close()
+
+	rec, err := cassette.New(path, cassette.Record, nil)
+	require.NoError(err)
+	core.UseClient(rec)
+
+	const (
+		callback = "https://shop.example/ipn?key=s3cr3t"
+		email    = "buyer@example.com"
+		payout   = "0xpayoutaddress"
+	)
+	ia := &payments.InvoiceArgs{
+		PaymentAmount: payments.PaymentAmount{PriceAmount: 10, PriceCurrency: "usd", OrderID: "order-2", CallbackURL: callback},
+	}
+	inv, err := payments.NewInvoice(ia)
+	require.NoError(err)
+	ipa := &payments.InvoicePaymentArgs{InvoiceID: inv.ID, PayCurrency: "btc", CustomerEmail: email, PayoutAddress: payout}
+	_, err = payments.NewFromInvoice(ipa)
+	require.NoError(err)
+	require.NoError(rec.Stop())
+
+	data, err := os.ReadFile(path)
+	require.NoError(err)
+	assert.NotContains(string(data), callback)
+	assert.NotContains(string(data), email)
+	assert.NotContains(string(data), payout)
+
+	// Redacted requests still match when replayed
+	rep, err := cassette.New(path, cassette.Replay, nil)
+	require.NoError(err)
+	core.UseClient(rep)
+	_, err = payments.NewInvoice(ia)
+	require.NoError(err)
+	_, err = payments.NewFromInvoice(ipa)
+	require.NoError(err)
+	assert.NoError(rep.Stop())
+}
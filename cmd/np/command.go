@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/config"
+	"github.com/CIDgravity/go-nowpayments/core"
+)
+
+// command is a CLI command, either running an action or grouping subcommands
+type command struct {
+	name  string
+	short string
+	subs  []*command
+	run   func(args []string) error
+}
+
+// exec runs the command, or the subcommand named by the first argument
+// path is the list of parent command names, used in help messages
+func (c *command) exec(path []string, args []string) error {
+	path = append(path, c.name)
+	if c.run != nil {
+		return c.run(args)
+	}
+
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" || args[0] == "help" {
+		c.usage(path)
+		if len(args) == 0 {
+			os.Exit(2)
+		}
+		return nil
+	}
+
+	for _, sub := range c.subs {
+		if sub.name == args[0] {
+			return sub.exec(path, args[1:])
+		}
+	}
+
+	c.usage(path)
+	return fmt.Errorf("unknown command %q", strings.Join(append(path, args[0]), " "))
+}
+
+func (c *command) usage(path []string) {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\n", strings.Join(path, " "))
+	if c.short != "" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", c.short)
+	}
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, sub := range c.subs {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", sub.name, sub.short)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for help on a command\n", strings.Join(path, " "))
+}
+
+// flags is the flag set of a command, holding the flags common to all commands
+type flags struct {
+	*flag.FlagSet
+	cfgFile *string
+	profile *string
+	debug   *bool
+	output  *string
+	columns *string
+}
+
+// newFlags returns the flag set of a command, args describing its positional arguments if any
+func newFlags(name, args, short string) *flags {
+	f := newFlagsWithoutOutput(name, args, short)
+	f.output = f.String("output", "json", "output format: "+strings.Join(outputFormats, ", "))
+	f.columns = f.String("columns", "", "comma separated list of the fields to output, e.g. payment_id,payment_status")
+	return f
+}
+
+// newFlagsWithoutOutput returns the flag set of a command not printing any result, without the -output and -columns flags
+func newFlagsWithoutOutput(name, args, short string) *flags {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	f := &flags{
+		FlagSet: fs,
+		cfgFile: fs.String("f", "", "JSON config file to use, instead of a profile"),
+		profile: fs.String("profile", os.Getenv(envProfile), "profile of the profiles file to use, the default one when not set"),
+		debug:   fs.Bool("debug", false, "turn debugging on"),
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: np %s [flags]", name)
+		if args != "" {
+			fmt.Fprintf(os.Stderr, " %s", args)
+		}
+		fmt.Fprintf(os.Stderr, "\n\n%s\n\nFlags:\n", short)
+		fs.PrintDefaults()
+	}
+	return f
+}
+
+// timeFlag is a time flag accepting the formats of the API timestamps, e.g. 2024-01-31 or 2024-01-31T12:00:00Z
+type timeFlag struct {
+	t *time.Time
+}
+
+func (f timeFlag) String() string {
+	if f.t == nil || f.t.IsZero() {
+		return ""
+	}
+	return f.t.Format(time.RFC3339)
+}
+
+func (f timeFlag) Set(s string) error {
+	v, err := core.ParseTime(s)
+	if err != nil {
+		return err
+	}
+	*f.t = v.Time
+	return nil
+}
+
+// TimeVar defines a time flag, a date or an RFC3339 timestamp, with no default value
+func (f *flags) TimeVar(p *time.Time, name, usage string) {
+	f.Var(timeFlag{p}, name, usage)
+}
+
+// parse parses the arguments, checks the required flags are set and configures the library
+func (f *flags) parse(args []string, required ...string) error {
+	if err := f.parseOnly(args, required...); err != nil {
+		return err
+	}
+	return f.setup()
+}
+
+// parseOnly parses the arguments and checks the required flags are set
+func (f *flags) parseOnly(args []string, required ...string) error {
+	f.Parse(args)
+
+	set := map[string]bool{}
+	f.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	for _, name := range required {
+		if !set[name] {
+			return fmt.Errorf("np %s: flag -%s is required", f.Name(), name)
+		}
+	}
+
+	if f.output == nil {
+		return nil
+	}
+	valid := false
+	for _, o := range outputFormats {
+		valid = valid || o == *f.output
+	}
+	if !valid {
+		return fmt.Errorf("np %s: unknown output format %q, expected one of %s", f.Name(), *f.output, strings.Join(outputFormats, ", "))
+	}
+	return nil
+}
+
+// setup loads the credentials and configures the API client
+func (f *flags) setup() error {
+	c, _, err := f.credentials()
+	if err != nil {
+		return err
+	}
+	if err := config.Load(c); err != nil {
+		return err
+	}
+	core.UseBaseURL(core.BaseURL(config.Server()))
+	core.UseClient(core.NewHTTPClient())
+
+	if *f.debug {
+		core.WithDebug(true)
+	}
+	return nil
+}
+
+// print writes v to the standard output, in the output format with the selected columns
+func (f *flags) print(v interface{}) error {
+	var selection []string
+	for _, c := range strings.Split(*f.columns, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			selection = append(selection, c)
+		}
+	}
+	return render(os.Stdout, *f.output, selection, v)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+
+	"github.com/CIDgravity/go-nowpayments/custody"
+	"github.com/CIDgravity/go-nowpayments/payments"
+)
+
+var custodyCmd = &command{
+	name:  "custody",
+	short: "manage Custody user accounts and their funds",
+	subs: []*command{
+		{name: "users", short: "create and list user accounts", subs: []*command{
+			{name: "create", short: "create a user account", run: custodyUserCreateCmd},
+			{name: "list", short: "list user accounts", run: custodyUserListCmd},
+		}},
+		{name: "balance", short: "show the balances of a user account", run: custodyBalanceCmd},
+		{name: "transfer", short: "transfer funds between user accounts", subs: []*command{
+			{name: "create", short: "transfer funds between user accounts", run: custodyTransferCreateCmd},
+			{name: "get", short: "show a transfer", run: custodyTransferGetCmd},
+			{name: "list", short: "list transfers", run: custodyTransferListCmd},
+		}},
+		{name: "deposit", short: "deposit funds on a user account", run: custodyDepositCmd},
+		{name: "write-off", short: "write funds off a user account to the master account", run: custodyWriteOffCmd},
+		{name: "payments", short: "list the deposit payments", run: custodyPaymentsCmd},
+		{name: "conversions", short: "convert funds between currencies", subs: []*command{
+			{name: "create", short: "convert funds", run: custodyConversionCreateCmd},
+			{name: "get", short: "show a conversion", run: custodyConversionGetCmd},
+			{name: "list", short: "list conversions", run: custodyConversionListCmd},
+		}},
+		{name: "treasury", short: "show the balances of all user accounts", run: custodyTreasuryCmd},
+		{name: "reconcile", short: "reconcile the ledger of a user account with its balances", run: custodyReconcileCmd},
+	},
+}
+
+// listFlags registers the common list flags
+func listFlags(f *flags, o *custody.ListCommonOptionsArgs) {
+	f.Int64Var(&o.Id, "id", 0, "only show this ID")
+	f.Int64Var(&o.Limit, "limit", 10, "number of results")
+	f.Int64Var(&o.Offset, "offset", 0, "number of results to skip")
+	f.StringVar(&o.Order, "order", "", "sort order, ASC or DESC")
+}
+
+func custodyUserCreateCmd(args []string) error {
+	f := newFlags("custody users create", "", "Create a Custody user account")
+	ua := &custody.UserAccountArgs{}
+	f.StringVar(&ua.Name, "name", "", "user account name")
+	if err := f.parse(args, "name"); err != nil {
+		return err
+	}
+
+	u, err := custody.NewUser(ua)
+	if err != nil {
+		return err
+	}
+	return f.print(u)
+}
+
+func custodyUserListCmd(args []string) error {
+	f := newFlags("custody users list", "", "List Custody user accounts")
+	o := &custody.ListCommonOptionsArgs{}
+	listFlags(f, o)
+	if err := f.parse(args); err != nil {
+		return err
+	}
+
+	us, err := custody.ListUsers(o)
+	if err != nil {
+		return err
+	}
+	return f.print(us)
+}
+
+func custodyBalanceCmd(args []string) error {
+	f := newFlags("custody balance", "", "Show the balances of a Custody user account")
+	user := f.String("user", "", "user account ID")
+	if err := f.parse(args, "user"); err != nil {
+		return err
+	}
+
+	b, err := custody.GetBalance(*user)
+	if err != nil {
+		return err
+	}
+	return f.print(b)
+}
+
+func custodyTransferCreateCmd(args []string) error {
+	f := newFlags("custody transfer create", "", "Transfer funds between Custody user accounts")
+	ta := &custody.TransferArgs{}
+	f.StringVar(&ta.FromID, "from", "", "user account ID to debit")
+	f.StringVar(&ta.ToID, "to", "", "user account ID to credit")
+	f.Float64Var(&ta.Amount, "amount", 0, "amount to transfer")
+	f.StringVar(&ta.Currency, "currency", "", "currency to transfer, e.g. usdtbsc")
+	wait := f.Bool("wait", false, "wait for the transfer to be final")
+	if err := f.parse(args, "from", "to", "amount", "currency"); err != nil {
+		return err
+	}
+
+	t, err := custody.NewTransfer(ta)
+	if err != nil {
+		return err
+	}
+	if *wait {
+		t, err = custody.WaitForTransfer(context.Background(), t.Id)
+		if err != nil {
+			return err
+		}
+	}
+	return f.print(t)
+}
+
+func custodyTransferGetCmd(args []string) error {
+	f := newFlags("custody transfer get", "", "Show a transfer")
+	id := f.String("id", "", "transfer ID")
+	if err := f.parse(args, "id"); err != nil {
+		return err
+	}
+
+	t, err := custody.GetTransfer(*id)
+	if err != nil {
+		return err
+	}
+	return f.print(t)
+}
+
+func custodyTransferListCmd(args []string) error {
+	f := newFlags("custody transfer list", "", "List transfers")
+	o := &custody.ListTransfersOptionArgs{}
+	listFlags(f, &o.ListCommonOptionsArgs)
+	f.StringVar(&o.Status, "status", "", "only show transfers with this status, e.g. FINISHED")
+	if err := f.parse(args); err != nil {
+		return err
+	}
+
+	ts, err := custody.ListTransfers(o)
+	if err != nil {
+		return err
+	}
+	return f.print(ts)
+}
+
+func custodyDepositCmd(args []string) error {
+	f := newFlags("custody deposit", "", "Deposit funds on a Custody user account, from the master account or with a payment")
+	da := &custody.DepositWithPaymentArgs{}
+	f.StringVar(&da.SubPartnerID, "user", "", "user account ID to credit")
+	f.StringVar(&da.Currency, "currency", "", "currency to deposit, e.g. usdtbsc")
+	f.Float64Var(&da.Amount, "amount", 0, "amount to deposit")
+	payment := f.Bool("payment", false, "create a payment to refill the account, instead of debiting the master account")
+	f.BoolVar(&da.IsFixedRate, "fixed-rate", false, "use a fixed-rate exchange (with -payment)")
+	f.BoolVar(&da.IsFeePaidByUser, "fee-paid-by-user", false, "all fees are paid by the user (with -payment)")
+	f.StringVar(&da.IpnCallbackURL, "callback-url", "", "URL receiving the IPN callbacks (with -payment)")
+	if err := f.parse(args, "user", "currency", "amount"); err != nil {
+		return err
+	}
+
+	if *payment {
+		p, err := custody.NewDepositWithPayment(da)
+		if err != nil {
+			return err
+		}
+		return f.print(p)
+	}
+
+	t, err := custody.NewDepositFroMasterAccount(&da.DepositArgs)
+	if err != nil {
+		return err
+	}
+	return f.print(t)
+}
+
+func custodyWriteOffCmd(args []string) error {
+	f := newFlags("custody write-off", "", "Write funds off a Custody user account to the master account")
+	da := &custody.DepositArgs{}
+	f.StringVar(&da.SubPartnerID, "user", "", "user account ID to debit")
+	f.StringVar(&da.Currency, "currency", "", "currency to write off, e.g. usdtbsc")
+	f.Float64Var(&da.Amount, "amount", 0, "amount to write off")
+	if err := f.parse(args, "user", "currency", "amount"); err != nil {
+		return err
+	}
+
+	t, err := custody.NewWriteOffToMaster(da)
+	if err != nil {
+		return err
+	}
+	return f.print(t)
+}
+
+func custodyPaymentsCmd(args []string) error {
+	f := newFlags("custody payments", "", "List the deposit payments of Custody user accounts")
+	o := &custody.ListPaymentsOption{}
+	f.Int64Var(&o.Limit, "limit", 10, "number of payments per page")
+	f.Int64Var(&o.Page, "page", 0, "page number, starting at 0")
+	f.Int64Var(&o.Id, "id", 0, "only show this payment ID")
+	f.StringVar(&o.SubPartnerID, "user", "", "only show the payments of this user account ID")
+	f.StringVar(&o.PayCurrency, "pay-currency", "", "only show payments in this currency")
+	f.StringVar(&o.Status, "status", "", "only show payments with this status")
+	f.TimeVar(&o.DateFrom, "date-from", "oldest creation date, e.g. 2024-01-31")
+	f.TimeVar(&o.DateTo, "date-to", "newest creation date, e.g. 2024-01-31")
+	f.StringVar(&o.SortBy, "sort-by", "", "sort field, e.g. created_at")
+	f.StringVar(&o.OrderBy, "order-by", "", "sort order, asc or desc")
+	if err := f.parse(args); err != nil {
+		return err
+	}
+
+	ps, err := custody.ListPayments(o)
+	if err != nil {
+		return err
+	}
+	return f.print(ps)
+}
+
+func custodyConversionCreateCmd(args []string) error {
+	f := newFlags("custody conversions create", "", "Convert the master account funds between currencies")
+	ca := &custody.ConversionArgs{}
+	f.Float64Var(&ca.Amount, "amount", 0, "amount to convert")
+	f.StringVar(&ca.FromCurrency, "from", "", "currency to convert from")
+	f.StringVar(&ca.ToCurrency, "to", "", "currency to convert to")
+	estimate := f.Bool("estimate", false, "only show the estimated converted amount")
+	if err := f.parse(args, "amount", "from", "to"); err != nil {
+		return err
+	}
+
+	if *estimate {
+		e, err := payments.EstimatedPrice(ca.Amount, ca.FromCurrency, ca.ToCurrency)
+		if err != nil {
+			return err
+		}
+		return f.print(e)
+	}
+
+	c, err := custody.NewConversion(ca)
+	if err != nil {
+		return err
+	}
+	return f.print(c)
+}
+
+func custodyConversionGetCmd(args []string) error {
+	f := newFlags("custody conversions get", "", "Show a conversion")
+	id := f.String("id", "", "conversion ID")
+	if err := f.parse(args, "id"); err != nil {
+		return err
+	}
+
+	c, err := custody.GetConversion(*id)
+	if err != nil {
+		return err
+	}
+	return f.print(c)
+}
+
+func custodyConversionListCmd(args []string) error {
+	f := newFlags("custody conversions list", "", "List conversions")
+	o := &custody.ListConversionsOptionArgs{}
+	listFlags(f, &o.ListCommonOptionsArgs)
+	f.StringVar(&o.Status, "status", "", "only show conversions with this status, e.g. FINISHED")
+	f.StringVar(&o.FromCurrency, "from", "", "only show conversions from this currency")
+	f.StringVar(&o.ToCurrency, "to", "", "only show conversions to this currency")
+	f.TimeVar(&o.DateFrom, "date-from", "oldest creation date, e.g. 2024-01-31")
+	f.TimeVar(&o.DateTo, "date-to", "newest creation date, e.g. 2024-01-31")
+	if err := f.parse(args); err != nil {
+		return err
+	}
+
+	cs, err := custody.ListConversions(o)
+	if err != nil {
+		return err
+	}
+	return f.print(cs)
+}
+
+func custodyTreasuryCmd(args []string) error {
+	f := newFlags("custody treasury", "", "Show the balances of all Custody user accounts, and their totals")
+	o := &custody.TreasuryOptions{}
+	f.IntVar(&o.Concurrency, "concurrency", 4, "maximum number of balance lookups running at the same time")
+	f.Int64Var(&o.PageSize, "page-size", 100, "number of users fetched per call")
+	if err := f.parse(args); err != nil {
+		return err
+	}
+
+	t, err := custody.TreasuryReport(context.Background(), o)
+	if err != nil {
+		return err
+	}
+	return f.print(t)
+}
+
+func custodyReconcileCmd(args []string) error {
+	f := newFlags("custody reconcile", "", "Rebuild the ledger of a Custody user account and compare it to its balances")
+	user := f.String("user", "", "user account ID")
+	o := &custody.LedgerOptions{}
+	f.StringVar(&o.MasterID, "master", "", "master account ID")
+	f.Float64Var(&o.Tolerance, "tolerance", 1e-8, "maximum difference allowed between the ledger and the balance")
+	if err := f.parse(args, "user"); err != nil {
+		return err
+	}
+
+	l, err := custody.NewLedger(*user, o)
+	if err != nil {
+		return err
+	}
+	r, err := l.Reconcile()
+	if err != nil {
+		return err
+	}
+	return f.print(r)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/CIDgravity/go-nowpayments/export"
+)
+
+var exportCmd = &command{
+	name:  "export",
+	short: "export payments to CSV or to a ledger or beancount journal",
+	run:   exportRunCmd,
+}
+
+func exportRunCmd(args []string) error {
+	f := newFlagsWithoutOutput("export", "[file]", "Export the payments created in a date range to CSV or to a double-entry journal\nThe export is written to stdout when no file is given")
+	o := &export.Options{}
+	f.TimeVar(&o.From, "from", "oldest creation date, inclusive, e.g. 2024-01-01")
+	f.TimeVar(&o.To, "to", "newest creation date, exclusive, e.g. 2024-02-01")
+	f.BoolVar(&o.Custody, "custody", false, "export the deposit payments of the Custody user accounts")
+	f.StringVar(&o.SubPartnerID, "user", "", "only export the deposit payments of this Custody user account ID")
+	format := f.String("format", "csv", "export format: csv, ledger or beancount")
+	jo := &export.JournalOptions{}
+	accounts := f.String("accounts", "", "comma separated accounts per currency, e.g. usdttrc20=Assets:Crypto:USDT")
+	f.StringVar(&jo.AssetsPrefix, "assets", "", "parent of the accounts of the currencies not in -accounts (default Assets:NOWPayments)")
+	f.StringVar(&jo.Income, "income", "", "income account (default Income:Sales)")
+	f.StringVar(&jo.Fees, "fees", "", "fees account (default Expenses:Fees:NOWPayments)")
+	statuses := f.String("statuses", "", "comma separated statuses of the payments written to journals (default finished)")
+	if err := f.parse(args); err != nil {
+		return err
+	}
+
+	switch *format {
+	case "csv":
+	case string(export.Ledger), string(export.Beancount):
+		jo.Format = export.JournalFormat(*format)
+	default:
+		return fmt.Errorf("np export: unknown format %q, expected one of csv, ledger, beancount", *format)
+	}
+
+	if *accounts != "" {
+		jo.Accounts = map[string]string{}
+		for _, a := range strings.Split(*accounts, ",") {
+			cur, acc, ok := strings.Cut(a, "=")
+			if !ok || cur == "" || acc == "" {
+				return fmt.Errorf("np export: bad account %q, expected currency=account", a)
+			}
+			jo.Accounts[strings.ToLower(strings.TrimSpace(cur))] = strings.TrimSpace(acc)
+		}
+	}
+	if *statuses != "" {
+		jo.Statuses = strings.Split(*statuses, ",")
+	}
+
+	ps, err := export.Fetch(o)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if name := f.Arg(0); name != "" && name != "-" {
+		file, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if jo.Format == "" {
+		return export.WriteCSV(out, ps)
+	}
+	return export.WriteJournal(out, ps, jo)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/config"
+	"github.com/CIDgravity/go-nowpayments/ipn"
+)
+
+var ipnCmd = &command{
+	name:  "ipn",
+	short: "replay IPN callbacks",
+	subs: []*command{
+		{name: "send", short: "POST a signed JSON payload to an IPN callback endpoint", run: ipnSendCmd},
+	},
+}
+
+func ipnSendCmd(args []string) error {
+	f := newFlagsWithoutOutput("ipn send", "[payload.json]", "POST a JSON payload, signed with the IPN secret key, to an IPN callback endpoint\nThe payload is read from stdin when no file is given")
+	url := f.String("url", "", "URL of the IPN callback endpoint")
+	retries := f.Int("retries", 3, "number of retries when the endpoint fails")
+	backoff := f.Duration("backoff", time.Second, "delay before the first retry")
+	if err := f.parse(args, "url"); err != nil {
+		return err
+	}
+	if config.IPNSecretKey() == "" {
+		return errors.New("IPN secret key is missing, set it in the profile or NOWPAYMENTS_IPN_SECRET")
+	}
+
+	in := os.Stdin
+	if name := f.Arg(0); name != "" && name != "-" {
+		var err error
+		in, err = os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+	payload, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	s := ipn.NewSender(config.IPNSecretKey())
+	s.Retries = *retries
+	s.Backoff = *backoff
+
+	code, err := s.Send(context.Background(), *url, payload)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Delivered, status:", code)
+	return nil
+}
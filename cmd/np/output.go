@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormats are the values of the --output flag
+var outputFormats = []string{"json", "table", "ndjson", "csv", "yaml"}
+
+// object is a JSON object keeping the order of its keys
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newObject() *object {
+	return &object{values: map[string]interface{}{}}
+}
+
+func (o *object) set(k string, v interface{}) {
+	if _, ok := o.values[k]; !ok {
+		o.keys = append(o.keys, k)
+	}
+	o.values[k] = v
+}
+
+func (o *object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		kd, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		vd, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(kd)
+		b.WriteByte(':')
+		b.Write(vd)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// tree returns the JSON representation of v, objects keeping the order of the struct fields
+func tree(v interface{}) (interface{}, error) {
+	d, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.UseNumber()
+	return decodeValue(dec)
+}
+
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		o := newObject()
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			o.set(k.(string), v)
+		}
+		_, err = dec.Token()
+		return o, err
+	case json.Delim('['):
+		a := []interface{}{}
+		for dec.More() {
+			v, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		_, err = dec.Token()
+		return a, err
+	}
+	return tok, nil
+}
+
+// rows flattens a tree into rows, one per element of a list, nested keys being joined with dots
+func rows(t interface{}) []*object {
+	items, ok := t.([]interface{})
+	if !ok {
+		items = []interface{}{t}
+	}
+
+	rs := make([]*object, 0, len(items))
+	for _, it := range items {
+		r := newObject()
+		if _, ok := it.(*object); ok {
+			flatten("", it, r)
+		} else {
+			r.set("value", it)
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+func flatten(prefix string, v interface{}, r *object) {
+	o, ok := v.(*object)
+	if !ok || len(o.keys) == 0 {
+		r.set(strings.TrimSuffix(prefix, "."), v)
+		return
+	}
+	for _, k := range o.keys {
+		flatten(prefix+k+".", o.values[k], r)
+	}
+}
+
+// columns returns the columns of the rows in order of appearance, restricted to the selection if any
+// A selected column also matches the nested keys below it, e.g. "balances" matches "balances.usdtbsc.amount"
+func columns(rs []*object, selection []string) []string {
+	var all []string
+	seen := map[string]bool{}
+	for _, r := range rs {
+		for _, k := range r.keys {
+			if !seen[k] {
+				seen[k] = true
+				all = append(all, k)
+			}
+		}
+	}
+	if len(selection) == 0 {
+		return all
+	}
+
+	var cols []string
+	for _, s := range selection {
+		found := false
+		for _, k := range all {
+			if k == s || strings.HasPrefix(k, s+".") {
+				cols = append(cols, k)
+				found = true
+			}
+		}
+		if !found {
+			cols = append(cols, s)
+		}
+	}
+	return cols
+}
+
+// project returns the rows restricted to the columns
+func project(rs []*object, cols []string) []*object {
+	ps := make([]*object, 0, len(rs))
+	for _, r := range rs {
+		p := newObject()
+		for _, c := range cols {
+			p.set(c, r.values[c])
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// cell returns the text of a flattened value
+func cell(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	}
+	d, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(d)
+}
+
+// render writes v to w in the given format, with the selected columns only if any
+func render(w io.Writer, format string, selection []string, v interface{}) error {
+	t, err := tree(v)
+	if err != nil {
+		return err
+	}
+
+	rs := rows(t)
+	cols := columns(rs, selection)
+	if len(selection) > 0 {
+		// Selected columns are rendered as flat objects
+		ps := project(rs, cols)
+		if _, ok := t.([]interface{}); ok {
+			items := make([]interface{}, len(ps))
+			for i, p := range ps {
+				items[i] = p
+			}
+			t = items
+		} else if len(ps) == 1 {
+			t = ps[0]
+		}
+		rs = ps
+	}
+
+	switch format {
+	case "json":
+		d, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(d))
+		return err
+
+	case "ndjson":
+		items, ok := t.([]interface{})
+		if !ok {
+			items = []interface{}{t}
+		}
+		for _, it := range items {
+			d, err := json.Marshal(it)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(w, string(d)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		header := make([]string, len(cols))
+		for i, c := range cols {
+			header[i] = strings.ToUpper(c)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, r := range rs {
+			line := make([]string, len(cols))
+			for i, c := range cols {
+				line[i] = cell(r.values[c])
+			}
+			fmt.Fprintln(tw, strings.Join(line, "\t"))
+		}
+		return tw.Flush()
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(cols); err != nil {
+			return err
+		}
+		for _, r := range rs {
+			line := make([]string, len(cols))
+			for i, c := range cols {
+				line[i] = cell(r.values[c])
+			}
+			if err := cw.Write(line); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case "yaml":
+		e := yaml.NewEncoder(w)
+		e.SetIndent(2)
+		if err := e.Encode(yamlNode(t)); err != nil {
+			return err
+		}
+		return e.Close()
+	}
+
+	return fmt.Errorf("unknown output format %q, expected one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+// yamlNode returns the YAML node of a tree, keeping the order of the keys
+func yamlNode(t interface{}) *yaml.Node {
+	switch v := t.(type) {
+	case *object:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		for _, k := range v.keys {
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k}, yamlNode(v.values[k]))
+		}
+		return n
+	case []interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, it := range v {
+			n.Content = append(n.Content, yamlNode(it))
+		}
+		return n
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(v.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: cell(v)}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: cell(t)}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type outputItem struct {
+	ID      string  `json:"id"`
+	Amount  float64 `json:"amount"`
+	Balance struct {
+		Currency string `json:"currency"`
+	} `json:"balance"`
+}
+
+func TestRender(t *testing.T) {
+	items := []outputItem{{ID: "2", Amount: 1.5}, {ID: "1", Amount: 10}}
+	items[0].Balance.Currency = "usdtbsc"
+
+	tests := []struct {
+		format  string
+		columns []string
+		v       interface{}
+		want    string
+	}{
+		{"json", nil, items[1], "{\n  \"id\": \"1\",\n  \"amount\": 10,\n  \"balance\": {\n    \"currency\": \"\"\n  }\n}\n"},
+		{"ndjson", []string{"id"}, items, "{\"id\":\"2\"}\n{\"id\":\"1\"}\n"},
+		{"csv", nil, items, "id,amount,balance.currency\n2,1.5,usdtbsc\n1,10,\n"},
+		{"csv", []string{"balance", "id"}, items, "balance.currency,id\nusdtbsc,2\n,1\n"},
+		{"table", []string{"id", "amount"}, items, "ID  AMOUNT\n2   1.5\n1   10\n"},
+		{"yaml", []string{"amount"}, items, "- amount: 1.5\n- amount: 10\n"},
+		{"csv", nil, []string{"btc", "xmr"}, "value\nbtc\nxmr\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var b bytes.Buffer
+			require.NoError(t, render(&b, tt.format, tt.columns, tt.v))
+			assert.Equal(t, tt.want, b.String())
+		})
+	}
+
+	assert.Error(t, render(&bytes.Buffer{}, "xml", nil, items))
+}
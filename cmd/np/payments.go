@@ -0,0 +1,169 @@
+package main
+
+import (
+	"github.com/CIDgravity/go-nowpayments/config"
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/payments"
+)
+
+var paymentsCmd = &command{
+	name:  "payments",
+	short: "create and follow payments",
+	subs: []*command{
+		{name: "create", short: "create a payment", run: paymentCreateCmd},
+		{name: "status", short: "show the status of a payment", run: paymentStatusCmd},
+		{name: "list", short: "list payments", run: paymentListCmd},
+		{name: "estimate", short: "estimate the price of an amount in another currency", run: paymentEstimateCmd},
+		{name: "min-amount", short: "show the minimum payment amount of a currency pair", run: paymentMinAmountCmd},
+	},
+}
+
+var invoicesCmd = &command{
+	name:  "invoices",
+	short: "create invoices and pay them",
+	subs: []*command{
+		{name: "create", short: "create an invoice", run: invoiceCreateCmd},
+		{name: "pay", short: "create a payment from an invoice", run: invoicePayCmd},
+	},
+}
+
+// paymentAmountFlags registers the flags of the amount of a payment or invoice
+func paymentAmountFlags(f *flags, pa *payments.PaymentAmount) {
+	f.Float64Var(&pa.PriceAmount, "price-amount", 0, "price of the order, in price currency")
+	f.StringVar(&pa.PriceCurrency, "price-currency", "", "fiat currency of the price, e.g. eur")
+	f.StringVar(&pa.PayCurrency, "pay-currency", "", "crypto currency to pay in, e.g. xmr")
+	f.StringVar(&pa.CallbackURL, "callback-url", "", "URL receiving the IPN callbacks")
+	f.StringVar(&pa.OrderID, "order-id", "", "order ID in your system")
+	f.StringVar(&pa.OrderDescription, "order-description", "", "order description")
+}
+
+func paymentCreateCmd(args []string) error {
+	f := newFlags("payments create", "", "Create a payment")
+	pa := &payments.PaymentArgs{}
+	paymentAmountFlags(f, &pa.PaymentAmount)
+	f.Float64Var(&pa.PayAmount, "pay-amount", 0, "amount to pay in pay currency, converted from price amount when not set")
+	f.BoolVar(&pa.FeePaidByUser, "fee-paid-by-user", false, "all fees are paid by the user (fixed-rate only)")
+	f.BoolVar(&pa.FixedRate, "fixed-rate", false, "use a fixed-rate exchange")
+	f.StringVar(&pa.PayoutAddress, "payout-address", "", "address receiving the funds, instead of the account one")
+	f.StringVar(&pa.PayoutCurrency, "payout-currency", "", "currency of the payout address")
+	f.StringVar(&pa.PayoutExtraID, "payout-extra-id", "", "extra ID, memo or tag of the payout address")
+	f.StringVar(&pa.PurchaseID, "purchase-id", "", "purchase ID, to make another payment for the same order")
+	pcase := f.String("case", "", "payment's case (sandbox only)")
+	if err := f.parse(args, "price-amount", "price-currency", "pay-currency"); err != nil {
+		return err
+	}
+	if config.Server() == core.SandBoxBaseURL {
+		pa.Case = *pcase
+	}
+
+	p, err := payments.New(pa)
+	if err != nil {
+		return err
+	}
+	return f.print(p)
+}
+
+func paymentStatusCmd(args []string) error {
+	f := newFlags("payments status", "", "Show the status of a payment")
+	id := f.String("id", "", "payment ID")
+	if err := f.parse(args, "id"); err != nil {
+		return err
+	}
+
+	ps, err := payments.Status(*id)
+	if err != nil {
+		return err
+	}
+	return f.print(ps)
+}
+
+func paymentListCmd(args []string) error {
+	f := newFlags("payments list", "", "List payments")
+	o := &payments.ListOption{}
+	f.IntVar(&o.Limit, "limit", 10, "number of payments per page")
+	f.IntVar(&o.Page, "page", 0, "page number, starting at 0")
+	f.StringVar(&o.SortBy, "sort-by", "", "sort field, e.g. created_at")
+	f.StringVar(&o.OrderBy, "order-by", "", "sort order, asc or desc")
+	f.TimeVar(&o.DateFrom, "date-from", "oldest creation date, e.g. 2024-01-31")
+	f.TimeVar(&o.DateTo, "date-to", "newest creation date, e.g. 2024-01-31")
+	if err := f.parse(args); err != nil {
+		return err
+	}
+
+	ps, err := payments.List(o)
+	if err != nil {
+		return err
+	}
+	return f.print(ps)
+}
+
+func paymentEstimateCmd(args []string) error {
+	f := newFlags("payments estimate", "", "Estimate the price of an amount in another currency")
+	amount := f.Float64("amount", 0, "amount to convert")
+	from := f.String("from", "", "currency of the amount")
+	to := f.String("to", "", "currency of the estimate")
+	if err := f.parse(args, "amount", "from", "to"); err != nil {
+		return err
+	}
+
+	e, err := payments.EstimatedPrice(*amount, *from, *to)
+	if err != nil {
+		return err
+	}
+	return f.print(e)
+}
+
+func paymentMinAmountCmd(args []string) error {
+	f := newFlags("payments min-amount", "", "Show the minimum payment amount of a currency pair")
+	from := f.String("from", "", "currency paid")
+	to := f.String("to", "", "currency received")
+	fiat := f.String("fiat-equivalent", "", "fiat currency to show the equivalent amount in, e.g. usd")
+	if err := f.parse(args, "from", "to"); err != nil {
+		return err
+	}
+
+	a, err := payments.MinimumAmount(*from, *to, *fiat)
+	if err != nil {
+		return err
+	}
+	return f.print(a)
+}
+
+func invoiceCreateCmd(args []string) error {
+	f := newFlags("invoices create", "", "Create an invoice, returning the URL to follow to pay it")
+	ia := &payments.InvoiceArgs{}
+	paymentAmountFlags(f, &ia.PaymentAmount)
+	f.StringVar(&ia.SuccessURL, "success-url", "", "URL the customer is redirected to after a successful payment")
+	f.StringVar(&ia.CancelURL, "cancel-url", "", "URL the customer is redirected to after a failed payment")
+	if err := f.parse(args, "price-amount", "price-currency"); err != nil {
+		return err
+	}
+
+	inv, err := payments.NewInvoice(ia)
+	if err != nil {
+		return err
+	}
+	return f.print(inv)
+}
+
+func invoicePayCmd(args []string) error {
+	f := newFlags("invoices pay", "", "Create a payment from an invoice")
+	ipa := &payments.InvoicePaymentArgs{}
+	f.StringVar(&ipa.InvoiceID, "invoice-id", "", "invoice ID")
+	f.StringVar(&ipa.PayCurrency, "pay-currency", "", "crypto currency to pay in, e.g. xmr")
+	f.StringVar(&ipa.PurchaseID, "purchase-id", "", "purchase ID, to make another payment for the same order")
+	f.StringVar(&ipa.OrderDescription, "order-description", "", "order description")
+	f.StringVar(&ipa.CustomerEmail, "customer-email", "", "customer email address")
+	f.StringVar(&ipa.PayoutAddress, "payout-address", "", "address receiving the funds, instead of the account one")
+	f.StringVar(&ipa.PayoutCurrency, "payout-currency", "", "currency of the payout address")
+	f.StringVar(&ipa.PayoutExtraID, "payout-extra-id", "", "extra ID, memo or tag of the payout address")
+	if err := f.parse(args, "invoice-id", "pay-currency"); err != nil {
+		return err
+	}
+
+	p, err := payments.NewFromInvoice(ipa)
+	if err != nil {
+		return err
+	}
+	return f.print(p)
+}
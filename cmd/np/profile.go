@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/CIDgravity/go-nowpayments/config"
+	"github.com/CIDgravity/go-nowpayments/core"
+)
+
+// Environment variables selecting the profile, the credentials ones being read by config.FromEnv
+const (
+	envConfig  = "NOWPAYMENTS_CONFIG"
+	envProfile = "NOWPAYMENTS_PROFILE"
+)
+
+// profiles is the content of the profiles file
+type profiles struct {
+	// Default is the profile used when none is selected
+	Default  string                         `json:"default,omitempty"`
+	Profiles map[string]*config.Credentials `json:"profiles"`
+}
+
+// profilesPath returns the path of the profiles file, in the user's config directory
+// unless NOWPAYMENTS_CONFIG is set
+func profilesPath() (string, error) {
+	if p := os.Getenv(envConfig); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "nowpayments", "config.json"), nil
+}
+
+// readProfiles reads the profiles file, returning an empty one when it doesn't exist
+func readProfiles() (*profiles, string, error) {
+	path, err := profilesPath()
+	if err != nil {
+		return nil, "", err
+	}
+
+	ps := &profiles{Profiles: map[string]*config.Credentials{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ps, path, nil
+	}
+	if err != nil {
+		return nil, path, err
+	}
+	if err := json.Unmarshal(data, ps); err != nil {
+		return nil, path, fmt.Errorf("%s: %w", path, err)
+	}
+	if ps.Profiles == nil {
+		ps.Profiles = map[string]*config.Credentials{}
+	}
+	return ps, path, nil
+}
+
+// write saves the profiles file, readable by the user only
+func (ps *profiles) write(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(ps, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// names returns the sorted profile names
+func (ps *profiles) names() []string {
+	var ns []string
+	for n := range ps.Profiles {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// credentials returns the credentials from the -f config file, or else from the selected profile,
+// overridden by the NOWPAYMENTS_* environment variables
+// The source of the credentials is returned along with them
+func (f *flags) credentials() (*config.Credentials, string, error) {
+	c := &config.Credentials{}
+	var source string
+
+	switch {
+	case *f.cfgFile != "":
+		data, err := os.ReadFile(*f.cfgFile)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", *f.cfgFile, err)
+		}
+		source = *f.cfgFile
+
+	default:
+		ps, path, err := readProfiles()
+		if err != nil {
+			return nil, "", err
+		}
+		name := *f.profile
+		if name == "" {
+			name = ps.Default
+		}
+		if p, ok := ps.Profiles[name]; ok {
+			*c = *p
+			source = fmt.Sprintf("%s (profile %s)", path, name)
+		} else if *f.profile != "" {
+			return nil, "", fmt.Errorf("profile %q not found in %s", name, path)
+		}
+	}
+
+	env := config.FromEnv()
+	fromEnv := *env != config.Credentials{}
+	*c = c.Merge(env)
+	if fromEnv && source != "" {
+		source += ", overridden by environment"
+	} else if fromEnv {
+		source = "environment"
+	}
+
+	if source == "" {
+		return nil, "", errors.New("no credentials: run 'np config init', set the NOWPAYMENTS_* environment variables or use -f")
+	}
+	return c, source, nil
+}
+
+var configCmd = &command{
+	name:  "config",
+	short: "manage the configuration profiles",
+	subs: []*command{
+		{name: "init", short: "create or update a profile", run: configInitCmd},
+		{name: "show", short: "show the configuration in use, secrets being redacted", run: configShowCmd},
+		{name: "check", short: "check the credentials against the API", run: configCheckCmd},
+	},
+}
+
+func configInitCmd(args []string) error {
+	f := newFlagsWithoutOutput("config init", "", "Create or update a profile of the profiles file, "+
+		"in the user's config directory unless NOWPAYMENTS_CONFIG is set")
+	c := &config.Credentials{}
+	f.StringVar(&c.APIKey, "api-key", "", "API key")
+	f.StringVar(&c.IPNSecretKey, "ipn-secret", "", "IPN secret key")
+	f.StringVar(&c.Login, "login", "", "account e-mail address, required for JWT calls")
+	f.StringVar(&c.Password, "password", "", "account password, required for JWT calls")
+	f.StringVar(&c.Server, "server", core.SandBoxBaseURL, "API URL")
+	makeDefault := f.Bool("default", false, "make it the default profile")
+	if err := f.parseOnly(args, "api-key"); err != nil {
+		return err
+	}
+	if *f.profile == "" {
+		return errors.New("np config init: flag -profile is required")
+	}
+
+	ps, path, err := readProfiles()
+	if err != nil {
+		return err
+	}
+	ps.Profiles[*f.profile] = c
+	if *makeDefault || ps.Default == "" {
+		ps.Default = *f.profile
+	}
+	if err := ps.write(path); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Profile %s saved to %s\n", *f.profile, path)
+	return nil
+}
+
+// redact hides a secret, only showing its last 4 characters
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	if len(s) <= 8 {
+		return "****"
+	}
+	return "****" + s[len(s)-4:]
+}
+
+func configShowCmd(args []string) error {
+	f := newFlags("config show", "", "Show the configuration in use, secrets being redacted")
+	if err := f.parseOnly(args); err != nil {
+		return err
+	}
+
+	c, source, err := f.credentials()
+	if err != nil {
+		return err
+	}
+	ps, path, err := readProfiles()
+	if err != nil {
+		return err
+	}
+
+	return f.print(struct {
+		Source       string   `json:"source"`
+		Server       string   `json:"server"`
+		APIKey       string   `json:"apiKey"`
+		IPNSecretKey string   `json:"ipnSecretKey"`
+		Login        string   `json:"login"`
+		Password     string   `json:"password"`
+		ProfilesFile string   `json:"profilesFile"`
+		Profiles     []string `json:"profiles"`
+		Default      string   `json:"default"`
+	}{
+		Source:       source,
+		Server:       c.Server,
+		APIKey:       redact(c.APIKey),
+		IPNSecretKey: redact(c.IPNSecretKey),
+		Login:        c.Login,
+		Password:     redact(c.Password),
+		ProfilesFile: path,
+		Profiles:     ps.names(),
+		Default:      ps.Default,
+	})
+}
+
+func configCheckCmd(args []string) error {
+	f := newFlagsWithoutOutput("config check", "", "Check the API key with the API status, and the login and password with an authentication")
+	if err := f.parse(args); err != nil {
+		return err
+	}
+
+	st, err := core.Status()
+	if err != nil {
+		return fmt.Errorf("API status: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "API status:", st)
+
+	if config.Login() == "" || config.Password() == "" {
+		fmt.Fprintln(os.Stderr, "Authentication: skipped, no login or password")
+		return nil
+	}
+	if _, err := core.AuthenticateFromConfig(); err != nil {
+		return fmt.Errorf("authentication: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Authentication: OK")
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"strconv"
+
+	recurringPayment "github.com/CIDgravity/go-nowpayments/recurring_payments"
+	"github.com/CIDgravity/go-nowpayments/subscriptions"
+)
+
+var plansCmd = &command{
+	name:  "plans",
+	short: "manage subscription plans",
+	subs: []*command{
+		{name: "create", short: "create a subscription plan", run: planCreateCmd},
+		{name: "update", short: "update a subscription plan", run: planUpdateCmd},
+		{name: "get", short: "show a subscription plan", run: planGetCmd},
+		{name: "list", short: "list subscription plans", run: planListCmd},
+	},
+}
+
+var recurringCmd = &command{
+	name:  "recurring",
+	short: "manage recurring payments (subscriptions to a plan)",
+	subs: []*command{
+		{name: "create", short: "subscribe an e-mail address or a Custody user account to a plan", run: recurringCreateCmd},
+		{name: "get", short: "show a recurring payment", run: recurringGetCmd},
+		{name: "list", short: "list recurring payments", run: recurringListCmd},
+		{name: "delete", short: "delete a recurring payment", run: recurringDeleteCmd},
+	},
+}
+
+// planFlags registers the flags of the plan creation and update
+func planFlags(f *flags, sa *subscriptions.SubscriptionArgs) {
+	f.StringVar(&sa.Title, "title", "", "plan title")
+	f.Int64Var(&sa.IntervalDay, "interval-day", 0, "number of days between payments")
+	f.Float64Var(&sa.Amount, "amount", 0, "amount of each payment")
+	f.StringVar(&sa.Currency, "currency", "", "currency of the amount, e.g. usd")
+}
+
+func planCreateCmd(args []string) error {
+	f := newFlags("plans create", "", "Create a subscription plan")
+	sa := &subscriptions.SubscriptionArgs{}
+	planFlags(f, sa)
+	if err := f.parse(args, "title", "interval-day", "amount", "currency"); err != nil {
+		return err
+	}
+
+	s, err := subscriptions.New(sa)
+	if err != nil {
+		return err
+	}
+	return f.print(s)
+}
+
+func planUpdateCmd(args []string) error {
+	f := newFlags("plans update", "", "Update a subscription plan, only the flags set are changed")
+	id := f.String("id", "", "plan ID")
+	sa := &subscriptions.SubscriptionArgs{}
+	planFlags(f, sa)
+	if err := f.parse(args, "id"); err != nil {
+		return err
+	}
+
+	s, err := subscriptions.Update(*id, sa)
+	if err != nil {
+		return err
+	}
+	return f.print(s)
+}
+
+func planGetCmd(args []string) error {
+	f := newFlags("plans get", "", "Show a subscription plan")
+	id := f.String("id", "", "plan ID")
+	if err := f.parse(args, "id"); err != nil {
+		return err
+	}
+
+	s, err := subscriptions.Get(*id)
+	if err != nil {
+		return err
+	}
+	return f.print(s)
+}
+
+func planListCmd(args []string) error {
+	f := newFlags("plans list", "", "List subscription plans")
+	o := &subscriptions.ListOption{}
+	f.IntVar(&o.Limit, "limit", 10, "number of plans")
+	f.IntVar(&o.Offset, "offset", 0, "number of plans to skip")
+	if err := f.parse(args); err != nil {
+		return err
+	}
+
+	ss, err := subscriptions.List(o)
+	if err != nil {
+		return err
+	}
+	return f.print(ss)
+}
+
+func recurringCreateCmd(args []string) error {
+	f := newFlags("recurring create", "", "Subscribe an e-mail address or a Custody user account to a plan")
+	plan := f.Int64("plan", 0, "plan ID")
+	email := f.String("email", "", "e-mail address receiving the payment links")
+	user := f.Int64("user", 0, "Custody user account ID paying from its balance")
+	if err := f.parse(args, "plan"); err != nil {
+		return err
+	}
+
+	if *email != "" {
+		rp, err := subscriptions.NewWithEmail(&subscriptions.EmailSubscriptionArgs{SubscriptionPlanID: *plan, Email: *email})
+		if err != nil {
+			return err
+		}
+		return f.print(rp)
+	}
+
+	rp, err := recurringPayment.New(&recurringPayment.RecurringPaymentArgs{SubscriptionPlanID: *plan, SubPartnerID: *user})
+	if err != nil {
+		return err
+	}
+	return f.print(rp)
+}
+
+func recurringGetCmd(args []string) error {
+	f := newFlags("recurring get", "", "Show a recurring payment")
+	id := f.String("id", "", "recurring payment ID")
+	if err := f.parse(args, "id"); err != nil {
+		return err
+	}
+
+	rp, err := recurringPayment.Get(*id)
+	if err != nil {
+		return err
+	}
+	return f.print(rp)
+}
+
+func recurringListCmd(args []string) error {
+	f := newFlags("recurring list", "", "List recurring payments")
+	o := &recurringPayment.ListOption{}
+	f.IntVar(&o.Limit, "limit", 10, "number of recurring payments")
+	f.IntVar(&o.Offset, "offset", 0, "number of recurring payments to skip")
+	active := f.String("active", "", "only show active (true) or inactive (false) recurring payments")
+	status := f.String("status", "", "only show recurring payments with this status, e.g. PAID")
+	plan := f.Int64("plan", 0, "only show the recurring payments of this plan ID")
+	if err := f.parse(args); err != nil {
+		return err
+	}
+
+	if *active != "" {
+		b, err := strconv.ParseBool(*active)
+		if err != nil {
+			return err
+		}
+		o.IsActive = &b
+	}
+	if *status != "" {
+		o.Status = status
+	}
+	if *plan != 0 {
+		o.SubscriptionPlanID = plan
+	}
+
+	rps, err := recurringPayment.List(o)
+	if err != nil {
+		return err
+	}
+	return f.print(rps)
+}
+
+func recurringDeleteCmd(args []string) error {
+	f := newFlags("recurring delete", "", "Delete a recurring payment")
+	id := f.String("id", "", "recurring payment ID")
+	if err := f.parse(args, "id"); err != nil {
+		return err
+	}
+
+	res, err := recurringPayment.Delete(*id)
+	if err != nil {
+		return err
+	}
+	return f.print(res)
+}
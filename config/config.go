@@ -5,6 +5,8 @@ import (
 	"errors"
 	"io"
 	"net/url"
+	"os"
+	"strings"
 
 	"github.com/rotisserie/eris"
 )
@@ -17,75 +19,170 @@ type Credentials struct {
 	Server       string `json:"server"`
 }
 
+// Feature is a part of the API requiring more credentials than the API key and server URL
+type Feature int
+
+const (
+	// JWT is required by the calls authenticated with the account login and password (custody, payments list, ...)
+	JWT Feature = iota + 1
+	// IPN is required to verify the signature of the IPN callbacks
+	IPN
+)
+
+// Environment variables read by LoadFromEnv
+const (
+	EnvAPIKey       = "NOWPAYMENTS_API_KEY"
+	EnvIPNSecretKey = "NOWPAYMENTS_IPN_SECRET"
+	EnvLogin        = "NOWPAYMENTS_LOGIN"
+	EnvPassword     = "NOWPAYMENTS_PASSWORD"
+	EnvServer       = "NOWPAYMENTS_SERVER"
+)
+
+// ValidationErrors lists all the problems found in credentials
+type ValidationErrors []error
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, err := range v {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
 var conf Credentials
 
 func configErr(err error) error {
 	return eris.Wrap(err, "config")
 }
 
-// Load parses a JSON file to get the required credentials to operate NOWPayment's API.
-func Load(c *Credentials) error {
-	if c == nil {
-		return configErr(errors.New("nil reader"))
-	}
+// Validate checks the credentials hold the values required by the features, the API key and server URL
+// being always required. All problems are reported at once, as ValidationErrors
+func (c *Credentials) Validate(features ...Feature) error {
+	var errs ValidationErrors
 
-	conf = *c
-
-	// Sanity checks.
-	if conf.APIKey == "" {
-		return configErr(errors.New("API key is missing"))
+	if c.APIKey == "" {
+		errs = append(errs, errors.New("API key is missing"))
 	}
-	if conf.IPNSecretKey == "" {
-		return configErr(errors.New("IPN secret key is missing"))
+	if c.Server == "" {
+		errs = append(errs, errors.New("server URL missing"))
+	} else if _, err := url.Parse(c.Server); err != nil {
+		errs = append(errs, errors.New("server URL parsing"))
 	}
-	if conf.Login == "" {
-		return configErr(errors.New("login info missing"))
+
+	for _, f := range features {
+		switch f {
+		case JWT:
+			if c.Login == "" {
+				errs = append(errs, errors.New("login info missing"))
+			}
+			if c.Password == "" {
+				errs = append(errs, errors.New("password info missing"))
+			}
+		case IPN:
+			if c.IPNSecretKey == "" {
+				errs = append(errs, errors.New("IPN secret key is missing"))
+			}
+		}
 	}
-	if conf.Password == "" {
-		return configErr(errors.New("password info missing"))
+
+	if len(errs) > 0 {
+		return errs
 	}
-	if conf.Server == "" {
-		return configErr(errors.New("server URL missing"))
-	} else {
-		_, err := url.Parse(conf.Server)
-		if err != nil {
-			return configErr(errors.New("server URL parsing"))
+	return nil
+}
+
+// Merge returns the credentials overridden by the non-empty values of others, in order
+func (c Credentials) Merge(others ...*Credentials) Credentials {
+	for _, o := range others {
+		if o == nil {
+			continue
+		}
+		if o.APIKey != "" {
+			c.APIKey = o.APIKey
+		}
+		if o.IPNSecretKey != "" {
+			c.IPNSecretKey = o.IPNSecretKey
+		}
+		if o.Login != "" {
+			c.Login = o.Login
+		}
+		if o.Password != "" {
+			c.Password = o.Password
+		}
+		if o.Server != "" {
+			c.Server = o.Server
 		}
 	}
+	return c
+}
 
+// Load sets the credentials required to operate NOWPayment's API.
+// The API key and server URL are always required, login and password only with the JWT feature,
+// and the IPN secret key only with the IPN feature
+func Load(c *Credentials, features ...Feature) error {
+	if c == nil {
+		return configErr(errors.New("nil credentials"))
+	}
+
+	conf = *c
+	resetSecrets()
+
+	if err := conf.Validate(features...); err != nil {
+		return configErr(err)
+	}
 	return nil
 }
 
 // LoadFromFile parses a JSON file to get the required credentials to operate NOWPayment's API.
-func LoadFromFile(r io.Reader) error {
-	if r == nil {
-		return configErr(errors.New("nil reader"))
-	}
-	conf = Credentials{}
-	d := json.NewDecoder(r)
-	err := d.Decode(&conf)
+func LoadFromFile(r io.Reader, features ...Feature) error {
+	c, err := decode(r)
 	if err != nil {
 		return configErr(err)
 	}
-	// Sanity checks.
-	if conf.APIKey == "" {
-		return configErr(errors.New("API key is missing"))
+	return Load(c, features...)
+}
+
+// FromEnv returns the credentials set in the NOWPAYMENTS_* environment variables
+func FromEnv() *Credentials {
+	return &Credentials{
+		APIKey:       os.Getenv(EnvAPIKey),
+		IPNSecretKey: os.Getenv(EnvIPNSecretKey),
+		Login:        os.Getenv(EnvLogin),
+		Password:     os.Getenv(EnvPassword),
+		Server:       os.Getenv(EnvServer),
 	}
-	if conf.Login == "" {
-		return configErr(errors.New("login info missing"))
+}
+
+// LoadFromEnv gets the credentials from the NOWPAYMENTS_* environment variables
+func LoadFromEnv(features ...Feature) error {
+	return Load(FromEnv(), features...)
+}
+
+// LoadMerged gets the credentials from a JSON file, overridden by the environment variables,
+// themselves overridden by the non-empty explicit values
+// r and explicit are both optional
+func LoadMerged(r io.Reader, explicit *Credentials, features ...Feature) error {
+	c := &Credentials{}
+	if r != nil {
+		var err error
+		if c, err = decode(r); err != nil {
+			return configErr(err)
+		}
 	}
-	if conf.Password == "" {
-		return configErr(errors.New("password info missing"))
+
+	merged := c.Merge(FromEnv(), explicit)
+	return Load(&merged, features...)
+}
+
+func decode(r io.Reader) (*Credentials, error) {
+	if r == nil {
+		return nil, errors.New("nil reader")
 	}
-	if conf.Server == "" {
-		return configErr(errors.New("server URL missing"))
-	} else {
-		_, err := url.Parse(conf.Server)
-		if err != nil {
-			return configErr(errors.New("server URL parsing"))
-		}
+	c := &Credentials{}
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return nil, err
 	}
-	return nil
+	return c, nil
 }
 
 // Login returns the email address to use with the API.
@@ -93,19 +190,37 @@ func Login() string {
 	return conf.Login
 }
 
-// Password returns the related password to use.
+// Password returns the related password to use, empty when it can't be resolved (see ResolvePassword).
 func Password() string {
-	return conf.Password
+	v, _ := ResolvePassword()
+	return v
 }
 
-// APIKey is the API key to use.
+// ResolvePassword returns the related password to use, or the error of its secret provider.
+func ResolvePassword() (string, error) {
+	return secret(func() *CachedSecret { return providers.password }, conf.Password)
+}
+
+// APIKey is the API key to use, empty when it can't be resolved (see ResolveAPIKey).
 func APIKey() string {
-	return conf.APIKey
+	v, _ := ResolveAPIKey()
+	return v
+}
+
+// ResolveAPIKey returns the API key to use, or the error of its secret provider.
+func ResolveAPIKey() (string, error) {
+	return secret(func() *CachedSecret { return providers.apiKey }, conf.APIKey)
 }
 
-// IPNSecretKey returns the related IPN secret key to use.
+// IPNSecretKey returns the related IPN secret key to use, empty when it can't be resolved (see ResolveIPNSecretKey).
 func IPNSecretKey() string {
-	return conf.IPNSecretKey
+	v, _ := ResolveIPNSecretKey()
+	return v
+}
+
+// ResolveIPNSecretKey returns the related IPN secret key to use, or the error of its secret provider.
+func ResolveIPNSecretKey() (string, error) {
+	return secret(func() *CachedSecret { return providers.ipnSecretKey }, conf.IPNSecretKey)
 }
 
 // Server returns URL to connect to the API service.
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"io"
 	"strings"
 	"testing"
@@ -19,22 +20,28 @@ func TestLoad(t *testing.T) {
 	emptyLoginCfg := Credentials{Server: "http://some.tld", APIKey: "key", Password: "mypass", IPNSecretKey: "key"}
 	emptyPasswordCfg := Credentials{Server: "http://some.tld", APIKey: "key", Login: "mylogin", IPNSecretKey: "key"}
 	emptyServerCfg := Credentials{APIKey: "key", Login: "mylogin", Password: "mypass", IPNSecretKey: "key"}
+	emptyIPNCfg := Credentials{Server: "http://some.tld", APIKey: "key", Login: "mylogin", Password: "mypass"}
 	tests := []struct {
-		name    string
-		r       *Credentials
-		wantErr bool
+		name     string
+		r        *Credentials
+		features []Feature
+		wantErr  bool
 	}{
-		{"nil reader", nil, true},
-		{"bad config", &Credentials{}, true},
-		{"valid config", &validCfg, false},
-		{"empty API key", &emptyAPIKeyCfg, true},
-		{"empty login", &emptyLoginCfg, true},
-		{"empty password", &emptyPasswordCfg, true},
-		{"empty server", &emptyServerCfg, true},
+		{"nil reader", nil, nil, true},
+		{"bad config", &Credentials{}, nil, true},
+		{"valid config", &validCfg, []Feature{JWT, IPN}, false},
+		{"empty API key", &emptyAPIKeyCfg, nil, true},
+		{"empty login", &emptyLoginCfg, []Feature{JWT}, true},
+		{"empty login without JWT", &emptyLoginCfg, []Feature{IPN}, false},
+		{"empty password", &emptyPasswordCfg, []Feature{JWT}, true},
+		{"empty password without JWT", &emptyPasswordCfg, nil, false},
+		{"empty server", &emptyServerCfg, nil, true},
+		{"empty IPN secret key", &emptyIPNCfg, []Feature{IPN}, true},
+		{"empty IPN secret key without IPN", &emptyIPNCfg, []Feature{JWT}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := Load(tt.r); (err != nil) != tt.wantErr {
+			if err := Load(tt.r, tt.features...); (err != nil) != tt.wantErr {
 				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -46,29 +53,85 @@ func TestLoadFromFile(t *testing.T) {
 	emptyLoginCfg := `{"server":"http://some.tld","apiKey":"key","password":"mypass","ipnSecretKey":"key"}`
 	emptyPasswordCfg := `{"server":"http://some.tld","login":"mylogin","apiKey":"key","ipnSecretKey":"key"}`
 	emptyServerCfg := `{"apiKey":"key","login":"mylogin","password":"mypass","ipnSecretKey":"key"}`
+	emptyIPNCfg := `{"server":"http://some.tld","apiKey":"key","login":"mylogin","password":"mypass"}`
 	validCfg := `{"server":"http://some.tld","apiKey":"key","login":"mylogin","password":"mypass","ipnSecretKey":"key"}`
 	tests := []struct {
-		name    string
-		r       io.Reader
-		wantErr bool
+		name     string
+		r        io.Reader
+		features []Feature
+		wantErr  bool
 	}{
-		{"nil reader", nil, true},
-		{"bad config", strings.NewReader("nojson"), true},
-		{"valid config", strings.NewReader(validCfg), false},
-		{"empty API key", strings.NewReader(emptyAPIKeyCfg), true},
-		{"empty login", strings.NewReader(emptyLoginCfg), true},
-		{"empty password", strings.NewReader(emptyPasswordCfg), true},
-		{"empty server", strings.NewReader(emptyServerCfg), true},
+		{"nil reader", nil, nil, true},
+		{"bad config", strings.NewReader("nojson"), nil, true},
+		{"valid config", strings.NewReader(validCfg), []Feature{JWT, IPN}, false},
+		{"empty API key", strings.NewReader(emptyAPIKeyCfg), nil, true},
+		{"empty login", strings.NewReader(emptyLoginCfg), []Feature{JWT}, true},
+		{"empty password", strings.NewReader(emptyPasswordCfg), []Feature{JWT}, true},
+		{"empty server", strings.NewReader(emptyServerCfg), nil, true},
+		{"empty IPN secret key", strings.NewReader(emptyIPNCfg), []Feature{IPN}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := LoadFromFile(tt.r); (err != nil) != tt.wantErr {
+			if err := LoadFromFile(tt.r, tt.features...); (err != nil) != tt.wantErr {
 				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
 
+func TestValidationErrors(t *testing.T) {
+	err := Load(&Credentials{Login: "mylogin"}, JWT, IPN)
+
+	var v ValidationErrors
+	if !errors.As(err, &v) {
+		t.Fatalf("Load() error = %v, want ValidationErrors", err)
+	}
+	want := "API key is missing; server URL missing; password info missing; IPN secret key is missing"
+	if v.Error() != want {
+		t.Errorf("Load() error = %q, want %q", v.Error(), want)
+	}
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	t.Setenv(EnvAPIKey, "envkey")
+	t.Setenv(EnvServer, "http://env.tld")
+	t.Setenv(EnvLogin, "")
+	t.Setenv(EnvPassword, "")
+	t.Setenv(EnvIPNSecretKey, "")
+
+	if err := LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv() error = %v", err)
+	}
+	if APIKey() != "envkey" || Server() != "http://env.tld" {
+		t.Errorf("LoadFromEnv() = %+v", conf)
+	}
+	if err := LoadFromEnv(JWT); err == nil {
+		t.Errorf("LoadFromEnv(JWT) error = nil, want missing login")
+	}
+}
+
+func TestLoadMerged(t *testing.T) {
+	t.Setenv(EnvAPIKey, "envkey")
+	t.Setenv(EnvServer, "")
+	t.Setenv(EnvLogin, "envlogin")
+	t.Setenv(EnvPassword, "")
+	t.Setenv(EnvIPNSecretKey, "")
+
+	file := `{"server":"http://some.tld","apiKey":"filekey","login":"filelogin","password":"filepass"}`
+	err := LoadMerged(strings.NewReader(file), &Credentials{Login: "explicit"}, JWT)
+	if err != nil {
+		t.Fatalf("LoadMerged() error = %v", err)
+	}
+	want := Credentials{Server: "http://some.tld", APIKey: "envkey", Login: "explicit", Password: "filepass"}
+	if conf != want {
+		t.Errorf("LoadMerged() = %+v, want %+v", conf, want)
+	}
+
+	if err := LoadMerged(nil, nil, IPN); err == nil {
+		t.Errorf("LoadMerged() error = nil, want missing server and IPN secret key")
+	}
+}
+
 func TestLogin(t *testing.T) {
 	Load(&validCfg)
 	tests := []struct {
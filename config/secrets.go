@@ -0,0 +1,357 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rotisserie/eris"
+	"golang.org/x/crypto/scrypt"
+)
+
+// SecretProvider resolves a secret value from a source
+type SecretProvider interface {
+	Secret() (string, error)
+}
+
+// SecretFunc is a function used as a SecretProvider
+type SecretFunc func() (string, error)
+
+// Secret calls the function
+func (f SecretFunc) Secret() (string, error) {
+	return f()
+}
+
+// Static returns a provider of a fixed value
+func Static(value string) SecretProvider {
+	return SecretFunc(func() (string, error) { return value, nil })
+}
+
+// EnvSecret returns a provider reading an environment variable, which must be set
+func EnvSecret(name string) SecretProvider {
+	return SecretFunc(func() (string, error) {
+		v, ok := os.LookupEnv(name)
+		if !ok || v == "" {
+			return "", eris.Errorf("secret: environment variable %s not set", name)
+		}
+		return v, nil
+	})
+}
+
+// FileSecret returns a provider reading a file, such as a Docker or Kubernetes secret
+// Surrounding whitespaces, like a trailing newline, are trimmed
+func FileSecret(path string) SecretProvider {
+	return SecretFunc(func() (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", eris.Wrap(err, "secret")
+		}
+		v := strings.TrimSpace(string(data))
+		if v == "" {
+			return "", eris.Errorf("secret: file %s is empty", path)
+		}
+		return v, nil
+	})
+}
+
+// CommandTimeout is the maximum duration of the commands run by CommandSecret
+var CommandTimeout = 30 * time.Second
+
+// CommandSecret returns a provider running an external command, e.g. a password manager CLI,
+// the secret being its trimmed standard output
+func CommandSecret(name string, args ...string) SecretProvider {
+	return SecretFunc(func() (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+		defer cancel()
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			msg := strings.TrimSpace(stderr.String())
+			if msg != "" {
+				err = fmt.Errorf("%w: %s", err, msg)
+			}
+			return "", eris.Wrapf(err, "secret: command %s", name)
+		}
+
+		v := strings.TrimSpace(stdout.String())
+		if v == "" {
+			return "", eris.Errorf("secret: command %s returned nothing", name)
+		}
+		return v, nil
+	})
+}
+
+// KeyfileSecret returns a provider reading the named secret from an encrypted keyfile written by WriteKeyfile
+// The key is resolved from another provider, e.g. an environment variable or a file
+func KeyfileSecret(path string, key SecretProvider, name string) SecretProvider {
+	return SecretFunc(func() (string, error) {
+		k, err := key.Secret()
+		if err != nil {
+			return "", err
+		}
+		secrets, err := ReadKeyfile(path, k)
+		if err != nil {
+			return "", err
+		}
+		v, ok := secrets[name]
+		if !ok || v == "" {
+			return "", eris.Errorf("secret: %s not found in keyfile %s", name, path)
+		}
+		return v, nil
+	})
+}
+
+// Parameters of the scrypt key derivation of the keyfiles written by WriteKeyfile
+const (
+	keyfileKDF     = "scrypt"
+	keyfileN       = 1 << 15
+	keyfileR       = 8
+	keyfileP       = 1
+	keyfileSaltLen = 16
+	keyfileMaxN    = 1 << 20
+)
+
+// keyfile is the content of a keyfile: the key derivation parameters along with the sealed secrets
+type keyfile struct {
+	KDF  string `json:"kdf"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt []byte `json:"salt"`
+	Data []byte `json:"data"`
+}
+
+// keyfileAEAD returns the AES-256-GCM cipher of a keyfile, the key being derived from the passphrase with scrypt
+func keyfileAEAD(passphrase string, kf *keyfile) (cipher.AEAD, error) {
+	if passphrase == "" {
+		return nil, errors.New("empty keyfile key")
+	}
+	if kf.KDF != keyfileKDF {
+		return nil, fmt.Errorf("unsupported key derivation %q", kf.KDF)
+	}
+	if kf.N > keyfileMaxN || len(kf.Salt) == 0 {
+		return nil, errors.New("invalid key derivation parameters")
+	}
+	k, err := scrypt.Key([]byte(passphrase), kf.Salt, kf.N, kf.R, kf.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// WriteKeyfile encrypts the named secrets with the passphrase, and writes them to path, readable by the user only
+func WriteKeyfile(path, passphrase string, secrets map[string]string) error {
+	kf := &keyfile{KDF: keyfileKDF, N: keyfileN, R: keyfileR, P: keyfileP, Salt: make([]byte, keyfileSaltLen)}
+	if _, err := io.ReadFull(rand.Reader, kf.Salt); err != nil {
+		return eris.Wrap(err, "keyfile")
+	}
+	aead, err := keyfileAEAD(passphrase, kf)
+	if err != nil {
+		return eris.Wrap(err, "keyfile")
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return eris.Wrap(err, "keyfile")
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return eris.Wrap(err, "keyfile")
+	}
+	kf.Data = aead.Seal(nonce, nonce, plain, nil)
+
+	data, err := json.Marshal(kf)
+	if err != nil {
+		return eris.Wrap(err, "keyfile")
+	}
+	return eris.Wrap(os.WriteFile(path, append(data, '\n'), 0o600), "keyfile")
+}
+
+// ReadKeyfile decrypts the secrets of a keyfile written by WriteKeyfile
+func ReadKeyfile(path, passphrase string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, eris.Wrap(err, "keyfile")
+	}
+	kf := &keyfile{}
+	if err := json.Unmarshal(data, kf); err != nil {
+		return nil, eris.Wrapf(err, "keyfile %s", path)
+	}
+	aead, err := keyfileAEAD(passphrase, kf)
+	if err != nil {
+		return nil, eris.Wrapf(err, "keyfile %s", path)
+	}
+	if len(kf.Data) < aead.NonceSize() {
+		return nil, eris.Errorf("keyfile %s: truncated", path)
+	}
+
+	nonce, sealed := kf.Data[:aead.NonceSize()], kf.Data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, eris.Errorf("keyfile %s: wrong key or corrupted file", path)
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, eris.Wrapf(err, "keyfile %s", path)
+	}
+	return secrets, nil
+}
+
+// CachedSecret caches the value of a provider, resolving it again once expired or invalidated
+// so that rotated secrets are picked up without restarting
+type CachedSecret struct {
+	p   SecretProvider
+	ttl time.Duration
+
+	mu    sync.Mutex
+	value string
+	at    time.Time
+	valid bool
+}
+
+// Cache returns a provider caching p values for ttl, forever when ttl is 0
+func Cache(p SecretProvider, ttl time.Duration) *CachedSecret {
+	return &CachedSecret{p: p, ttl: ttl}
+}
+
+// Secret returns the cached value, resolving it when expired
+func (c *CachedSecret) Secret() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.valid && (c.ttl == 0 || time.Since(c.at) < c.ttl) {
+		return c.value, nil
+	}
+
+	v, err := c.p.Secret()
+	if err != nil {
+		return "", err
+	}
+	c.value, c.at, c.valid = v, time.Now(), true
+	return v, nil
+}
+
+// Invalidate forces the value to be resolved again on the next call
+func (c *CachedSecret) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.valid = false
+}
+
+// SecretCredentials are credentials resolved from secret providers
+// Nil providers are left empty
+type SecretCredentials struct {
+	APIKey       SecretProvider
+	IPNSecretKey SecretProvider
+	Login        string
+	Password     SecretProvider
+	Server       string
+	// TTL is the duration the secrets are cached, forever when 0
+	TTL time.Duration
+}
+
+// providers are used by the credential getters, once loaded with LoadSecrets
+var providers struct {
+	sync.RWMutex
+	apiKey, ipnSecretKey, password *CachedSecret
+}
+
+// LoadSecrets resolves the credentials from the providers, validates them for the features like Load does,
+// then keeps the providers to resolve again the secrets once their TTL is expired or RefreshSecrets is called
+// If a secret can't be resolved again later, the error is returned by the Resolve getters
+func LoadSecrets(sc *SecretCredentials, features ...Feature) error {
+	if sc == nil {
+		return configErr(errors.New("nil secret credentials"))
+	}
+
+	c := &Credentials{Login: sc.Login, Server: sc.Server}
+	cache := func(p SecretProvider, into *string, errs *ValidationErrors) *CachedSecret {
+		if p == nil {
+			return nil
+		}
+		cs := Cache(p, sc.TTL)
+		v, err := cs.Secret()
+		if err != nil {
+			*errs = append(*errs, err)
+		}
+		*into = v
+		return cs
+	}
+
+	var errs ValidationErrors
+	apiKey := cache(sc.APIKey, &c.APIKey, &errs)
+	ipnSecretKey := cache(sc.IPNSecretKey, &c.IPNSecretKey, &errs)
+	password := cache(sc.Password, &c.Password, &errs)
+	if len(errs) > 0 {
+		return configErr(errs)
+	}
+
+	if err := Load(c, features...); err != nil {
+		return err
+	}
+
+	providers.Lock()
+	providers.apiKey, providers.ipnSecretKey, providers.password = apiKey, ipnSecretKey, password
+	providers.Unlock()
+	return nil
+}
+
+// resetSecrets drops the providers loaded with LoadSecrets
+func resetSecrets() {
+	providers.Lock()
+	providers.apiKey, providers.ipnSecretKey, providers.password = nil, nil, nil
+	providers.Unlock()
+}
+
+// RefreshSecrets resolves again all the secrets loaded with LoadSecrets, e.g. after a rotation
+func RefreshSecrets() error {
+	providers.RLock()
+	defer providers.RUnlock()
+
+	var errs ValidationErrors
+	for _, cs := range []*CachedSecret{providers.apiKey, providers.ipnSecretKey, providers.password} {
+		if cs == nil {
+			continue
+		}
+		cs.Invalidate()
+		if _, err := cs.Secret(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return configErr(errs)
+	}
+	return nil
+}
+
+// secret returns the value of a loaded secret, or value when none is loaded
+func secret(which func() *CachedSecret, value string) (string, error) {
+	providers.RLock()
+	cs := which()
+	providers.RUnlock()
+
+	if cs == nil {
+		return value, nil
+	}
+	return cs.Secret()
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSecretProviders(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("NP_TEST_SECRET", "from-env")
+	t.Setenv("NP_TEST_KEYFILE_KEY", "0123456789abcdef0123456789abcdef")
+
+	secretFile := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keyfile := filepath.Join(dir, "keyfile")
+	if err := WriteKeyfile(keyfile, "0123456789abcdef0123456789abcdef", map[string]string{"apiKey": "from-keyfile"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		p       SecretProvider
+		want    string
+		wantErr bool
+	}{
+		{"static", Static("value"), "value", false},
+		{"env", EnvSecret("NP_TEST_SECRET"), "from-env", false},
+		{"env unset", EnvSecret("NP_TEST_UNSET"), "", true},
+		{"file", FileSecret(secretFile), "from-file", false},
+		{"file missing", FileSecret(filepath.Join(dir, "missing")), "", true},
+		{"command", CommandSecret("echo", "from-command"), "from-command", false},
+		{"command failing", CommandSecret("false"), "", true},
+		{"keyfile", KeyfileSecret(keyfile, EnvSecret("NP_TEST_KEYFILE_KEY"), "apiKey"), "from-keyfile", false},
+		{"keyfile wrong key", KeyfileSecret(keyfile, Static("wrong"), "apiKey"), "", true},
+		{"keyfile missing name", KeyfileSecret(keyfile, EnvSecret("NP_TEST_KEYFILE_KEY"), "password"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Secret()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Secret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Secret() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyfileSalt(t *testing.T) {
+	dir := t.TempDir()
+	secrets := map[string]string{"apiKey": "key"}
+
+	var files []keyfile
+	for _, name := range []string{"a", "b"} {
+		path := filepath.Join(dir, name)
+		if err := WriteKeyfile(path, "passphrase", secrets); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kf := keyfile{}
+		if err := json.Unmarshal(data, &kf); err != nil {
+			t.Fatalf("keyfile is not JSON: %v", err)
+		}
+		files = append(files, kf)
+	}
+
+	if files[0].KDF != "scrypt" || files[0].N != keyfileN || len(files[0].Salt) != keyfileSaltLen {
+		t.Errorf("keyfile parameters = %s %d %d", files[0].KDF, files[0].N, len(files[0].Salt))
+	}
+	if bytes.Equal(files[0].Salt, files[1].Salt) {
+		t.Errorf("keyfiles share the same salt")
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "api-key")
+	if err := os.WriteFile(keyFile, []byte("key-1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	password := SecretFunc(func() (string, error) {
+		calls++
+		return "pass", nil
+	})
+
+	err := LoadSecrets(&SecretCredentials{
+		APIKey:   FileSecret(keyFile),
+		Login:    "mylogin",
+		Password: password,
+		Server:   "http://some.tld",
+		TTL:      time.Hour,
+	}, JWT)
+	if err != nil {
+		t.Fatalf("LoadSecrets() error = %v", err)
+	}
+	if APIKey() != "key-1" || Password() != "pass" || Login() != "mylogin" {
+		t.Errorf("LoadSecrets() = %q %q %q", APIKey(), Password(), Login())
+	}
+	if calls != 1 {
+		t.Errorf("password resolved %d times, want cached", calls)
+	}
+
+	// Rotation
+	if err := os.WriteFile(keyFile, []byte("key-2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if APIKey() != "key-1" {
+		t.Errorf("APIKey() = %q, want cached key-1", APIKey())
+	}
+	if err := RefreshSecrets(); err != nil {
+		t.Fatalf("RefreshSecrets() error = %v", err)
+	}
+	if APIKey() != "key-2" {
+		t.Errorf("APIKey() = %q, want rotated key-2", APIKey())
+	}
+
+	// Error returned when the source disappears
+	os.Remove(keyFile)
+	if err := RefreshSecrets(); err == nil {
+		t.Errorf("RefreshSecrets() error = nil, want missing file")
+	}
+	if v, err := ResolveAPIKey(); err == nil {
+		t.Errorf("ResolveAPIKey() = %q, want missing file error", v)
+	}
+	if APIKey() != "" {
+		t.Errorf("APIKey() = %q, want empty", APIKey())
+	}
+
+	// Load drops the providers
+	Load(&validCfg)
+	if APIKey() != "key" {
+		t.Errorf("APIKey() = %q, want key", APIKey())
+	}
+
+	err = LoadSecrets(&SecretCredentials{APIKey: EnvSecret("NP_TEST_UNSET"), Server: "http://some.tld"})
+	if err == nil {
+		t.Errorf("LoadSecrets() error = nil, want unresolved API key")
+	}
+}
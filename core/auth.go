@@ -3,6 +3,9 @@ package core
 import (
 	"fmt"
 	"strings"
+
+	"github.com/CIDgravity/go-nowpayments/config"
+	"github.com/rotisserie/eris"
 )
 
 type token struct {
@@ -28,3 +31,12 @@ func Authenticate(email, password string) (string, error) {
 	err := HTTPSend(par)
 	return t.Token, err
 }
+
+// AuthenticateFromConfig obtains a JWT token with the login and password of the configuration
+func AuthenticateFromConfig() (string, error) {
+	password, err := config.ResolvePassword()
+	if err != nil {
+		return "", eris.Wrap(err, "auth")
+	}
+	return Authenticate(config.Login(), password)
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/rotisserie/eris"
+)
+
+// CallOptions are the options of Call
+type CallOptions struct {
+	// RouteName names the call for the middlewares, logs and errors, "METHOD path" when empty
+	// When it names a route of the library or one registered with RegisterRoute, the method, path and envelope of
+	// the route are used unless given
+	RouteName string
+	// Values are the query parameters
+	Values url.Values
+	// JWT authenticates the call with a JWT token obtained with the configured login and password
+	JWT bool
+	// JWTToken authenticates the call with this JWT token
+	JWTToken string
+	// Envelope is the envelope of the response, detected when AutoEnvelope and the route isn't registered
+	Envelope Envelope
+}
+
+// Call sends a request to an endpoint, possibly not wrapped by the library, and returns its decoded response
+// The path is relative to the base URL, e.g. /payment/123. req is encoded in JSON as the request body when not nil.
+// Like the wrapped endpoints, the API key is sent, the response is unwrapped from its envelope, errors are returned
+// as *APIError and the call goes through the middlewares
+func Call[Resp any](ctx context.Context, method, path string, req any, opts *CallOptions) (*Resp, error) {
+	if opts == nil {
+		opts = &CallOptions{}
+	}
+	r := routeAttr{method: strings.ToUpper(method), path: path, envelope: opts.Envelope}
+	if reg := route(opts.RouteName); reg.path != "" {
+		if r.method == "" {
+			r.method = reg.method
+		}
+		if r.path == "" {
+			r.path = reg.path
+		}
+		if r.envelope == AutoEnvelope {
+			r.envelope = reg.envelope
+		}
+	}
+	name := opts.RouteName
+	if name == "" {
+		name = r.method + " " + r.path
+	}
+	if r.method == "" || !strings.HasPrefix(r.path, "/") {
+		return nil, eris.Errorf("bad call %q: method and path starting with / are required", name)
+	}
+
+	var body io.Reader
+	if req != nil {
+		d, err := json.Marshal(req)
+		if err != nil {
+			return nil, eris.Wrap(err, name)
+		}
+		body = bytes.NewReader(d)
+	}
+
+	token := opts.JWTToken
+	if token == "" && opts.JWT {
+		var err error
+		token, err = AuthenticateFromConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp := new(Resp)
+	err := do(&SendParams{
+		Context:   ctx,
+		Body:      body,
+		Into:      resp,
+		RouteName: name,
+		Values:    opts.Values,
+		JWTToken:  token,
+	}, r)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
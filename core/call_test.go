@@ -0,0 +1,158 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/CIDgravity/go-nowpayments/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type payout struct {
+	ID     string  `json:"id"`
+	Amount float64 `json:"amount"`
+}
+
+func TestCall(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defaultURL = "host"
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		req     any
+		opts    *CallOptions
+		wantErr bool
+		init    func(*mocks.HTTPClient)
+		after   func(*payout, error)
+	}{
+		{"bad path", http.MethodGet, "payout", nil, nil, true, nil, nil},
+		{"GET", http.MethodGet, "/payout/1", nil, &CallOptions{Values: url.Values{"a": {"b"}}}, false,
+			func(c *mocks.HTTPClient) {
+				c.EXPECT().Do(mock.Anything).Run(func(req *http.Request) {
+					assert.Equal(http.MethodGet, req.Method)
+					assert.Equal("host/payout/1", req.URL.Path)
+					assert.Equal("b", req.URL.Query().Get("a"))
+					assert.Equal("key", req.Header.Get("X-API-KEY"))
+					assert.Empty(req.Header.Get("Authorization"))
+					assert.Nil(req.Body)
+				}).Return(newResponseOK(`{"id":"1","amount":2.5}`), nil)
+			},
+			func(p *payout, err error) {
+				require.NoError(err)
+				assert.Equal(&payout{ID: "1", Amount: 2.5}, p)
+			},
+		},
+		{"POST with JWT", "post", "/payout", payout{Amount: 1}, &CallOptions{JWT: true}, false,
+			func(c *mocks.HTTPClient) {
+				c.EXPECT().Do(mock.Anything).Return(newResponseOK(`{"token":"tok"}`), nil).Once()
+				c.EXPECT().Do(mock.Anything).Run(func(req *http.Request) {
+					assert.Equal(http.MethodPost, req.Method)
+					assert.Equal("Bearer tok", req.Header.Get("Authorization"))
+					assert.Equal("application/json", req.Header.Get("Content-Type"))
+					var b bytes.Buffer
+					b.ReadFrom(req.Body)
+					assert.JSONEq(`{"id":"","amount":1}`, b.String())
+				}).Return(newResponse(http.StatusCreated, `{"id":"2","amount":1}`), nil).Once()
+			},
+			func(p *payout, err error) {
+				require.NoError(err)
+				assert.Equal("2", p.ID)
+			},
+		},
+		{"API error", http.MethodGet, "/payout/3", nil, nil, true,
+			func(c *mocks.HTTPClient) {
+				c.EXPECT().Do(mock.Anything).Return(newResponse(http.StatusNotFound, `{"code":"NOT_FOUND"}`), nil)
+			},
+			func(p *payout, err error) {
+				var apiErr *APIError
+				require.True(errors.As(err, &apiErr))
+				assert.Equal("NOT_FOUND", apiErr.Code)
+				assert.Nil(p)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mocks.NewHTTPClient(t)
+			UseClient(c)
+			if tt.init != nil {
+				tt.init(c)
+			}
+			p, err := Call[payout](context.Background(), tt.method, tt.path, tt.req, tt.opts)
+			if tt.wantErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+			if tt.after != nil {
+				tt.after(p, err)
+			}
+		})
+	}
+}
+
+func TestRegisterRoute(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defaultURL = "host"
+	t.Cleanup(func() {
+		routesMu.Lock()
+		delete(routes, "payout-single")
+		routesMu.Unlock()
+	})
+
+	assert.Error(RegisterRoute("", http.MethodGet, "/payout", NoEnvelope))
+	assert.Error(RegisterRoute("payout-single", http.MethodGet, "payout", NoEnvelope))
+	require.NoError(RegisterRoute("payout-single", "get", "/payout", NoEnvelope))
+
+	c := mocks.NewHTTPClient(t)
+	UseClient(c)
+	c.EXPECT().Do(mock.Anything).Run(func(req *http.Request) {
+		assert.Equal(http.MethodGet, req.Method)
+		assert.Equal("host/payout/1", req.URL.Path)
+	}).Return(newResponseOK(`{"id":"1"}`), nil)
+
+	p := &payout{}
+	require.NoError(HTTPSend(&SendParams{RouteName: "payout-single", Path: "1", Into: &p}))
+	assert.Equal("1", p.ID)
+}
+
+func TestCallRegisteredRoute(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defaultURL = "host"
+	t.Cleanup(func() {
+		routesMu.Lock()
+		delete(routes, "payout-single")
+		routesMu.Unlock()
+	})
+	require.NoError(RegisterRoute("payout-single", http.MethodGet, "/payout", ResultEnvelope))
+
+	c := mocks.NewHTTPClient(t)
+	UseClient(c)
+	c.EXPECT().Do(mock.Anything).Run(func(req *http.Request) {
+		assert.Equal(http.MethodGet, req.Method)
+		assert.Equal("host/payout", req.URL.Path)
+	}).Return(newResponseOK(`{"result":{"id":"1","amount":2.5}}`), nil).Once()
+	c.EXPECT().Do(mock.Anything).Run(func(req *http.Request) {
+		assert.Equal("host/payout/2", req.URL.Path)
+	}).Return(newResponseOK(`{"id":"2"}`), nil).Once()
+
+	// The method, path and envelope of the route are used unless given
+	p, err := Call[payout](context.Background(), "", "", nil, &CallOptions{RouteName: "payout-single"})
+	require.NoError(err)
+	assert.Equal(&payout{ID: "1", Amount: 2.5}, p)
+
+	_, err = Call[payout](context.Background(), http.MethodGet, "/payout/2", nil,
+		&CallOptions{RouteName: "payout-single", Envelope: NoEnvelope})
+	require.NoError(err)
+}
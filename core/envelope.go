@@ -0,0 +1,216 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Envelope is the key the responses of a route are wrapped in, if any
+type Envelope int
+
+const (
+	// AutoEnvelope detects the envelope of each response: a result or data key the decoded type doesn't have
+	AutoEnvelope Envelope = iota
+	// NoEnvelope responses are at the root level
+	NoEnvelope
+	// DataEnvelope responses are under a data key, like the payments list
+	DataEnvelope
+	// ResultEnvelope responses are under a result key, like the custody and subscriptions ones
+	ResultEnvelope
+)
+
+func (e Envelope) String() string {
+	switch e {
+	case NoEnvelope:
+		return "none"
+	case DataEnvelope:
+		return "data"
+	case ResultEnvelope:
+		return "result"
+	}
+	return "auto"
+}
+
+// key returns the JSON key of the envelope
+func (e Envelope) key() string {
+	switch e {
+	case DataEnvelope:
+		return "data"
+	case ResultEnvelope:
+		return "result"
+	}
+	return ""
+}
+
+// ShapeError is returned when a response doesn't have the shape of the type it's decoded into
+type ShapeError struct {
+	RouteName string
+	// Expected and Got describe the shapes, e.g. object and array of 2 elements
+	Expected string
+	Got      string
+}
+
+func (e *ShapeError) Error() string {
+	return fmt.Sprintf("%s: unexpected response shape: expected %s, got %s", e.RouteName, e.Expected, e.Got)
+}
+
+// IsShapeError tells if err is a ShapeError
+func IsShapeError(err error) bool {
+	var se *ShapeError
+	return errors.As(err, &se)
+}
+
+// target returns the type values are decoded into, pointers being dereferenced
+func target(into interface{}) reflect.Type {
+	t := reflect.TypeOf(into)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// hasField tells if t is decoded from a JSON object having the key, maps and unknown types having them all
+func hasField(t reflect.Type, key string) bool {
+	if t == nil {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Map, reflect.Interface:
+		return true
+	case reflect.Struct:
+	default:
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && hasField(ft, key) {
+				return true
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.EqualFold(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// shape describes the shape of a JSON value
+func shape(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return "nothing"
+	}
+	switch raw[0] {
+	case '{':
+		return "object"
+	case '[':
+		var a []json.RawMessage
+		if json.Unmarshal(raw, &a) == nil {
+			return fmt.Sprintf("array of %d elements", len(a))
+		}
+		return "array"
+	case '"':
+		return "string"
+	case 'n':
+		return "null"
+	case 't', 'f':
+		return "boolean"
+	}
+	return "number"
+}
+
+// expected describes the shape of the JSON values decoded into t
+func expected(t reflect.Type) string {
+	if t == nil {
+		return "any"
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Interface:
+		return "any"
+	}
+	return "number"
+}
+
+// decodeEnvelope decodes a response body into into, unwrapping its envelope and single element arrays
+// A key of the envelope is only unwrapped when the type decoded doesn't have it, so that types
+// declaring the envelope, like V2ResponseFormat, are still decoded as is
+func decodeEnvelope(route string, env Envelope, body []byte, into interface{}) error {
+	t := target(into)
+	raw := json.RawMessage(bytes.TrimSpace(body))
+
+	var keys []string
+	switch env {
+	case AutoEnvelope:
+		keys = []string{"result", "data"}
+	case DataEnvelope, ResultEnvelope:
+		keys = []string{env.key()}
+	}
+
+	if len(keys) > 0 && len(raw) > 0 && raw[0] == '{' {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return err
+		}
+		found := false
+		for _, k := range keys {
+			if v, ok := obj[k]; ok && !hasField(t, k) {
+				raw, found = v, true
+				break
+			}
+		}
+		if !found && env != AutoEnvelope && !hasField(t, env.key()) {
+			return &ShapeError{RouteName: route, Expected: "object with a " + env.key() + " key", Got: "object without"}
+		}
+	}
+
+	// A single object may be wrapped in an array
+	if len(raw) > 0 && raw[0] == '[' && t != nil {
+		switch t.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Interface:
+		default:
+			var a []json.RawMessage
+			if err := json.Unmarshal(raw, &a); err != nil {
+				return err
+			}
+			if len(a) != 1 {
+				return &ShapeError{RouteName: route, Expected: expected(t), Got: shape(raw)}
+			}
+			raw = a[0]
+		}
+	}
+
+	if err := json.Unmarshal(raw, into); err != nil {
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &te) && te.Field == "" {
+			return &ShapeError{RouteName: route, Expected: expected(t), Got: shape(raw)}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	ID string `json:"id"`
+}
+
+type page struct {
+	Data  []item `json:"data"`
+	Total int    `json:"total"`
+}
+
+func TestDecodeEnvelope(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tests := []struct {
+		name    string
+		env     Envelope
+		body    string
+		into    func() interface{}
+		want    interface{}
+		wantErr string
+	}{
+		{"root", NoEnvelope, `{"id":"1"}`, func() interface{} { return &item{} }, &item{ID: "1"}, ""},
+		{"result", ResultEnvelope, `{"result":{"id":"1"}}`, func() interface{} { return &item{} }, &item{ID: "1"}, ""},
+		{"data", DataEnvelope, `{"data":[{"id":"1"}],"total":1}`, func() interface{} { return &[]item{} },
+			&[]item{{ID: "1"}}, ""},
+		{"auto result", AutoEnvelope, `{"result":[{"id":"1"}]}`, func() interface{} { return &[]item{} },
+			&[]item{{ID: "1"}}, ""},
+		{"auto data", AutoEnvelope, `{"data":[{"id":"1"}]}`, func() interface{} { return &[]item{} },
+			&[]item{{ID: "1"}}, ""},
+		{"auto root", AutoEnvelope, `{"id":"1"}`, func() interface{} { return &item{} }, &item{ID: "1"}, ""},
+		{"type declaring the envelope", DataEnvelope, `{"data":[{"id":"1"}],"total":1}`,
+			func() interface{} { return &page{} }, &page{Data: []item{{ID: "1"}}, Total: 1}, ""},
+		{"V2ResponseFormat", ResultEnvelope, `{"result":{"id":"1"}}`,
+			func() interface{} { return &V2ResponseFormat[*item]{} }, &V2ResponseFormat[*item]{Result: &item{ID: "1"}}, ""},
+		{"pointer to pointer", ResultEnvelope, `{"result":{"id":"1"}}`,
+			func() interface{} { i := &item{}; return &i }, func() interface{} { i := &item{ID: "1"}; return &i }(), ""},
+		{"single element array", ResultEnvelope, `{"result":[{"id":"1"}]}`, func() interface{} { return &item{} },
+			&item{ID: "1"}, ""},
+		{"empty array", ResultEnvelope, `{"result":[]}`, func() interface{} { return &item{} }, nil,
+			"route: unexpected response shape: expected object, got array of 0 elements"},
+		{"many elements array", NoEnvelope, `[{"id":"1"},{"id":"2"}]`, func() interface{} { return &item{} }, nil,
+			"route: unexpected response shape: expected object, got array of 2 elements"},
+		{"object instead of array", NoEnvelope, `{"id":"1"}`, func() interface{} { return &[]item{} }, nil,
+			"route: unexpected response shape: expected array, got object"},
+		{"missing envelope", ResultEnvelope, `{"id":"1"}`, func() interface{} { return &item{} }, nil,
+			"route: unexpected response shape: expected object with a result key, got object without"},
+		{"map", AutoEnvelope, `{"result":1}`, func() interface{} { return &map[string]int{} },
+			&map[string]int{"result": 1}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			into := tt.into()
+			err := decodeEnvelope("route", tt.env, []byte(tt.body), into)
+			if tt.wantErr != "" {
+				require.Error(err)
+				assert.True(IsShapeError(err))
+				assert.Equal(tt.wantErr, err.Error())
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tt.want, into)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package core
+
+import "fmt"
+
+// APIError is returned when NOWPayment's API answers with an error status code
+type APIError struct {
+	// HTTPStatus is the status code of the HTTP response
+	HTTPStatus int
+	// StatusCode, Code and Message are the error details found in the response body
+	StatusCode int    `json:"statusCode"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("code %d (%s): %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Temporary returns true if the request may succeed when sent again (server side errors)
+func (e *APIError) Temporary() bool {
+	return e.HTTPStatus >= 500 || e.HTTPStatus == 429
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var strict = false
+
+// WithStrict makes the response types decoding fail on the fields they don't know, to catch API changes in tests
+// Otherwise the unknown fields are kept in their Extra field
+func WithStrict(s bool) {
+	strict = s
+}
+
+// UnknownFieldsError is returned in strict mode when a response has fields its type doesn't know
+type UnknownFieldsError struct {
+	Type   string
+	Fields []string
+}
+
+func (e *UnknownFieldsError) Error() string {
+	return fmt.Sprintf("%s: unknown fields %s", e.Type, strings.Join(e.Fields, ", "))
+}
+
+// fieldsCache caches the JSON keys of the struct types
+var fieldsCache sync.Map
+
+// fields returns the lower-cased JSON keys decoded into the struct type t, including the embedded structs ones
+func fields(t reflect.Type) map[string]bool {
+	if f, ok := fieldsCache.Load(t); ok {
+		return f.(map[string]bool)
+	}
+
+	f := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" || (!sf.IsExported() && !sf.Anonymous) {
+			continue
+		}
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k := range fields(ft) {
+					f[k] = true
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		f[strings.ToLower(name)] = true
+	}
+
+	fieldsCache.Store(t, f)
+	return f
+}
+
+// DecodeExtra decodes data into v, a pointer to a struct without UnmarshalJSON method, and returns the keys
+// v doesn't know, to be kept in an Extra field. In strict mode, an *UnknownFieldsError is returned instead
+// Response types implement UnmarshalJSON with it on an alias of themselves
+func DecodeExtra(data []byte, v interface{}) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil || all == nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(v).Elem()
+	known := fields(t)
+	var extra map[string]json.RawMessage
+	for k, raw := range all {
+		if known[strings.ToLower(k)] {
+			continue
+		}
+		if extra == nil {
+			extra = map[string]json.RawMessage{}
+		}
+		extra[k] = raw
+	}
+
+	if strict && len(extra) > 0 {
+		e := &UnknownFieldsError{Type: t.Name()}
+		for k := range extra {
+			e.Fields = append(e.Fields, k)
+		}
+		sort.Strings(e.Fields)
+		return nil, e
+	}
+	return extra, nil
+}
+
+// EncodeExtra encodes v, a struct without MarshalJSON method, along with the extra keys it doesn't know, sorted
+// Response types implement MarshalJSON with it on an alias of themselves
+func EncodeExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	known := fields(t)
+
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		if !known[strings.ToLower(k)] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	b.Write(data[:len(data)-1])
+	empty := bytes.Equal(bytes.TrimSpace(data), []byte("{}"))
+	for _, k := range keys {
+		if !empty {
+			b.WriteByte(',')
+		}
+		empty = false
+		name, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(extra[k])
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type base struct {
+	Currency string `json:"currency"`
+}
+
+type resource struct {
+	base
+	ID     ID     `json:"id"`
+	Amount Float  `json:"amount,omitempty"`
+	Secret string `json:"-"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (r *resource) UnmarshalJSON(data []byte) error {
+	type alias resource
+	extra, err := DecodeExtra(data, (*alias)(r))
+	if err != nil {
+		return err
+	}
+	r.Extra = extra
+	return nil
+}
+
+func (r resource) MarshalJSON() ([]byte, error) {
+	type alias resource
+	return EncodeExtra(alias(r), r.Extra)
+}
+
+func TestExtra(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	t.Cleanup(func() { WithStrict(false) })
+
+	body := `{"id":1,"currency":"btc","Amount":"2.5","new_field":{"a":[1,2]},"another":"x"}`
+
+	r := &resource{}
+	require.NoError(json.Unmarshal([]byte(body), r))
+	assert.Equal(ID("1"), r.ID)
+	assert.Equal("btc", r.Currency)
+	assert.Equal(Float(2.5), r.Amount)
+	assert.Equal(map[string]json.RawMessage{
+		"new_field": json.RawMessage(`{"a":[1,2]}`),
+		"another":   json.RawMessage(`"x"`),
+	}, r.Extra)
+
+	data, err := json.Marshal(r)
+	require.NoError(err)
+	assert.Equal(`{"currency":"btc","id":1,"amount":2.5,"another":"x","new_field":{"a":[1,2]}}`, string(data))
+
+	data, err = json.Marshal(resource{Extra: map[string]json.RawMessage{"id": json.RawMessage(`"dup"`)}})
+	require.NoError(err)
+	assert.Equal(`{"currency":"","id":null}`, string(data), "known keys not duplicated")
+
+	r = &resource{}
+	require.NoError(json.Unmarshal([]byte(`{"id":"2"}`), r))
+	assert.Nil(r.Extra)
+
+	WithStrict(true)
+	err = json.Unmarshal([]byte(body), &resource{})
+	var ufe *UnknownFieldsError
+	require.ErrorAs(err, &ufe)
+	assert.Equal([]string{"another", "new_field"}, ufe.Fields)
+	assert.Equal("alias: unknown fields another, new_field", err.Error())
+	assert.NoError(json.Unmarshal([]byte(`{"id":"2","currency":"btc"}`), &resource{}))
+}
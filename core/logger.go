@@ -0,0 +1,205 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger logs the API calls with levels, *slog.Logger implements it
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// LogBodyLimit is the maximum number of bytes logged for request and response bodies, 0 disabling bodies logging
+var LogBodyLimit = 2048
+
+// Redacted replaces the credentials in the logs
+const Redacted = "REDACTED"
+
+var (
+	loggerMu sync.RWMutex
+	logger   Logger
+
+	// sensitiveField matches the JSON string fields holding credentials or personal data
+	sensitiveField = regexp.MustCompile(`(?i)("[^"]*(?:email|password|token|secret|api_?key)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+)
+
+// UseLogger sets the logger of the API calls, nil disabling logging
+// Each call is logged with its route name, status and duration. Requests and response bodies are logged at
+// debug level, credentials, emails and passwords being redacted
+func UseLogger(l Logger) {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+
+	logger = l
+}
+
+// currentLogger returns the logger in use, a standard error one when debugging without logger
+func currentLogger() Logger {
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+
+	if logger != nil {
+		return logger
+	}
+	if debug {
+		return stderrLogger
+	}
+	return nil
+}
+
+// stdLogger is a Logger writing key=value lines with a standard logger
+type stdLogger struct {
+	l *log.Logger
+}
+
+var stderrLogger = NewStdLogger(log.New(os.Stderr, "nowpayments ", log.LstdFlags))
+
+// NewStdLogger returns a Logger writing "LEVEL msg key=value ..." lines with l
+func NewStdLogger(l *log.Logger) Logger {
+	return &stdLogger{l}
+}
+
+func (s *stdLogger) log(level, msg string, args []any) {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%q", args[i], fmt.Sprint(args[i+1]))
+	}
+	s.l.Print(b.String())
+}
+
+func (s *stdLogger) Debug(msg string, args ...any) { s.log("DEBUG", msg, args) }
+func (s *stdLogger) Info(msg string, args ...any)  { s.log("INFO", msg, args) }
+func (s *stdLogger) Warn(msg string, args ...any)  { s.log("WARN", msg, args) }
+func (s *stdLogger) Error(msg string, args ...any) { s.log("ERROR", msg, args) }
+
+// capBuffer keeps the first limit bytes written to it
+type capBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (c *capBuffer) Write(p []byte) (int, error) {
+	if room := c.limit - c.buf.Len(); room < len(p) {
+		c.truncated = true
+		if room > 0 {
+			c.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return c.buf.Write(p)
+}
+
+// String returns the redacted content
+func (c *capBuffer) String() string {
+	s := redactBody(c.buf.String())
+	if c.truncated {
+		s += "...(truncated)"
+	}
+	return s
+}
+
+// teeBody copies what is read from a body into a capped buffer
+type teeBody struct {
+	io.Reader
+	io.Closer
+}
+
+// capture returns a body copying what is read from b to a capped buffer, b is returned when bodies are not logged
+func capture(b io.ReadCloser) (io.ReadCloser, *capBuffer) {
+	if b == nil || LogBodyLimit <= 0 {
+		return b, nil
+	}
+	c := &capBuffer{limit: LogBodyLimit}
+	return &teeBody{io.TeeReader(b, c), b}, c
+}
+
+// redactBody hides the credentials and personal data of a JSON body, possibly truncated
+func redactBody(s string) string {
+	return sensitiveField.ReplaceAllString(s, `$1"`+Redacted+`"`)
+}
+
+// redactHeaders returns the request headers to log, credentials being redacted
+func redactHeaders(h http.Header) map[string]string {
+	m := make(map[string]string, len(h))
+	for k := range h {
+		v := h.Get(k)
+		switch http.CanonicalHeaderKey(k) {
+		case "X-Api-Key":
+			v = Redacted
+		case "Authorization":
+			if i := strings.IndexByte(v, ' '); i > 0 {
+				v = v[:i+1] + Redacted
+			} else {
+				v = Redacted
+			}
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// callLog logs a single API call
+type callLog struct {
+	l       Logger
+	route   string
+	req     *http.Request
+	reqBody *capBuffer
+	resBody *capBuffer
+	status  int
+	start   time.Time
+}
+
+// newCallLog logs the request at debug level and captures its body
+func newCallLog(l Logger, route string, req *http.Request) *callLog {
+	c := &callLog{l: l, route: route, req: req, start: time.Now()}
+	if req.Body != nil {
+		req.Body, c.reqBody = capture(req.Body)
+	}
+	c.l.Debug("nowpayments request", "route", route, "method", req.Method, "url", req.URL.String(),
+		"headers", redactHeaders(req.Header))
+	return c
+}
+
+// response captures the response body
+func (c *callLog) response(res *http.Response) {
+	c.status = res.StatusCode
+	res.Body, c.resBody = capture(res.Body)
+}
+
+// done logs the outcome of the call once the response has been decoded
+func (c *callLog) done(err error) {
+	d := time.Since(c.start)
+	args := []any{"route", c.route, "method", c.req.Method, "status", c.status, "duration", d}
+
+	if c.reqBody != nil && c.reqBody.buf.Len() > 0 {
+		c.l.Debug("nowpayments request body", "route", c.route, "body", c.reqBody.String())
+	}
+	if c.resBody != nil && c.resBody.buf.Len() > 0 {
+		c.l.Debug("nowpayments response body", "route", c.route, "status", c.status, "body", c.resBody.String())
+	}
+
+	switch {
+	case err == nil:
+		c.l.Info("nowpayments call", args...)
+	case c.status == 0 || c.status >= 500:
+		c.l.Error("nowpayments call failed", append(args, "error", err.Error())...)
+	default:
+		c.l.Warn("nowpayments call failed", append(args, "error", err.Error())...)
+	}
+}
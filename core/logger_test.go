@@ -0,0 +1,154 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CIDgravity/go-nowpayments/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type logEntry struct {
+	level string
+	msg   string
+	args  map[string]any
+}
+
+// recLogger records the log entries
+type recLogger struct {
+	entries []logEntry
+}
+
+func (r *recLogger) add(level, msg string, args []any) {
+	e := logEntry{level, msg, map[string]any{}}
+	for i := 0; i+1 < len(args); i += 2 {
+		e.args[fmt.Sprint(args[i])] = args[i+1]
+	}
+	r.entries = append(r.entries, e)
+}
+
+func (r *recLogger) Debug(msg string, args ...any) { r.add("DEBUG", msg, args) }
+func (r *recLogger) Info(msg string, args ...any)  { r.add("INFO", msg, args) }
+func (r *recLogger) Warn(msg string, args ...any)  { r.add("WARN", msg, args) }
+func (r *recLogger) Error(msg string, args ...any) { r.add("ERROR", msg, args) }
+
+func (r *recLogger) find(msg string) *logEntry {
+	for i := range r.entries {
+		if r.entries[i].msg == msg {
+			return &r.entries[i]
+		}
+	}
+	return nil
+}
+
+func TestUseLogger(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defaultURL = "host"
+	t.Cleanup(func() { UseLogger(nil) })
+
+	tests := []struct {
+		name  string
+		p     *SendParams
+		init  func(*mocks.HTTPClient)
+		after func(*recLogger, error)
+	}{
+		{"success", &SendParams{
+			RouteName: "auth",
+			Body:      strings.NewReader(`{"email":"me@some.tld","password":"secret"}`),
+			JWTToken:  "jwt",
+		}, func(c *mocks.HTTPClient) {
+			c.EXPECT().Do(mock.Anything).Call.Return(func(req *http.Request) *http.Response {
+				var b bytes.Buffer
+				b.ReadFrom(req.Body)
+				assert.Equal(`{"email":"me@some.tld","password":"secret"}`, b.String(), "request body unchanged")
+				return newResponseOK(`{"token":"eyJhbGciOi"}`)
+			}, nil)
+		}, func(r *recLogger, err error) {
+			require.NoError(err)
+
+			e := r.find("nowpayments request")
+			require.NotNil(e)
+			assert.Equal("DEBUG", e.level)
+			h := e.args["headers"].(map[string]string)
+			assert.Equal(Redacted, h["X-Api-Key"])
+			assert.Equal("Bearer "+Redacted, h["Authorization"])
+
+			e = r.find("nowpayments request body")
+			require.NotNil(e)
+			assert.Equal(`{"email":"REDACTED","password":"REDACTED"}`, e.args["body"])
+
+			e = r.find("nowpayments response body")
+			require.NotNil(e)
+			assert.Equal(`{"token":"REDACTED"}`, e.args["body"])
+
+			e = r.find("nowpayments call")
+			require.NotNil(e)
+			assert.Equal("INFO", e.level)
+			assert.Equal("auth", e.args["route"])
+			assert.Equal(http.StatusOK, e.args["status"])
+			assert.NotNil(e.args["duration"])
+		}},
+		{"API error", &SendParams{RouteName: "status"}, func(c *mocks.HTTPClient) {
+			c.EXPECT().Do(mock.Anything).Return(newResponse(http.StatusBadRequest, `{"statusCode":400,"code":"BAD","message":"bad"}`), nil)
+		}, func(r *recLogger, err error) {
+			require.Error(err)
+			e := r.find("nowpayments call failed")
+			require.NotNil(e)
+			assert.Equal("WARN", e.level)
+			assert.Equal(http.StatusBadRequest, e.args["status"])
+		}},
+		{"network error", &SendParams{RouteName: "status"}, func(c *mocks.HTTPClient) {
+			c.EXPECT().Do(mock.Anything).Return(nil, errors.New("network error"))
+		}, func(r *recLogger, err error) {
+			require.Error(err)
+			e := r.find("nowpayments call failed")
+			require.NotNil(e)
+			assert.Equal("ERROR", e.level)
+			assert.Equal("status: network error", e.args["error"])
+		}},
+		{"capped body", &SendParams{RouteName: "status"}, func(c *mocks.HTTPClient) {
+			c.EXPECT().Do(mock.Anything).Return(newResponseOK(`{"a":"`+strings.Repeat("x", 3000)+`","email":"me@some.tld"}`), nil)
+		}, func(r *recLogger, err error) {
+			require.NoError(err)
+			e := r.find("nowpayments response body")
+			require.NotNil(e)
+			body := e.args["body"].(string)
+			assert.True(strings.HasSuffix(body, "...(truncated)"))
+			assert.LessOrEqual(len(body), LogBodyLimit+len("...(truncated)"))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mocks.NewHTTPClient(t)
+			UseClient(c)
+			tt.init(c)
+			r := &recLogger{}
+			UseLogger(r)
+
+			into := map[string]any{}
+			err := HTTPSend(&SendParams{RouteName: tt.p.RouteName, Body: tt.p.Body, JWTToken: tt.p.JWTToken, Into: &into})
+			tt.after(r, err)
+		})
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	assert.Equal(t, `{"customer_email":"REDACTED","ipnSecretKey":"REDACTED","amount":1}`,
+		redactBody(`{"customer_email":"a@b.c","ipnSecretKey":"s\"x","amount":1}`))
+	assert.Equal(t, `{"password":"REDACTED"`, redactBody(`{"password":"trunc`))
+}
+
+func TestStdLogger(t *testing.T) {
+	var b bytes.Buffer
+	l := NewStdLogger(log.New(&b, "", 0))
+	l.Info("call", "route", "status", "status", 200)
+	assert.Equal(t, "INFO call route=\"status\" status=\"200\"\n", b.String())
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/rotisserie/eris"
+)
+
+// Invocation is an API call going through the middlewares
+type Invocation struct {
+	// Context of the call, never nil
+	Context   context.Context
+	RouteName string
+	Method    string
+	Params    *SendParams
+	// Request is the HTTP request sent, middlewares can add headers to it before calling the next round trip
+	Request *http.Request
+	// StatusCode is the HTTP status of the last response, 0 until one is received
+	StatusCode int
+	// Attempt is the number of times the request has been sent
+	Attempt int
+	// RateLimited is the number of responses rate limiting the call, with a 429 status code
+	RateLimited int
+
+	envelope Envelope
+}
+
+// RoundTrip sends a call and decodes its response into Params.Into
+// The error returned is the decoded one, an *APIError when the API rejected the call
+type RoundTrip func(c *Invocation) error
+
+// Middleware wraps a RoundTrip to add cross-cutting concerns to the API calls: headers, tracing, metrics, audit...
+// Calling next more than once sends the request again, e.g. to retry it
+type Middleware func(next RoundTrip) RoundTrip
+
+var (
+	middlewaresMu sync.RWMutex
+	middlewares   []Middleware
+)
+
+// UseMiddleware registers middlewares wrapping all the API calls, in order: the first one registered is the
+// outermost one, seeing the call first and its error last
+func UseMiddleware(mws ...Middleware) {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+
+	for _, mw := range mws {
+		if mw != nil {
+			middlewares = append(middlewares, mw)
+		}
+	}
+}
+
+// ClearMiddleware removes all the registered middlewares
+func ClearMiddleware() {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+
+	middlewares = nil
+}
+
+// chain returns rt wrapped by the registered middlewares
+func chain(rt RoundTrip) RoundTrip {
+	middlewaresMu.RLock()
+	defer middlewaresMu.RUnlock()
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+	return rt
+}
+
+// roundTrip is the innermost RoundTrip, sending the request with the client and logging it
+func roundTrip(c *Invocation) (err error) {
+	c.Attempt++
+	if c.Attempt > 1 {
+		if err := rewind(c.Request); err != nil {
+			return eris.Wrap(err, c.RouteName)
+		}
+	}
+	c.StatusCode = 0
+
+	if l := currentLogger(); l != nil {
+		cl := newCallLog(l, c.RouteName, c.Request)
+		defer func() { cl.done(err) }()
+		return send(c, cl)
+	}
+	return send(c, nil)
+}
+
+// rewind resets the request body to send it again
+func rewind(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.GetBody == nil {
+		return eris.New("request body can't be sent again")
+	}
+	b, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = b
+	return nil
+}
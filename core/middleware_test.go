@@ -0,0 +1,127 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CIDgravity/go-nowpayments/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUseMiddleware(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defaultURL = "host"
+	t.Cleanup(ClearMiddleware)
+
+	// record appends the route name, method and decoded error seen by the middleware
+	record := func(name string, seen *[]string) Middleware {
+		return func(next RoundTrip) RoundTrip {
+			return func(c *Invocation) error {
+				*seen = append(*seen, name+">"+c.RouteName+" "+c.Method)
+				err := next(c)
+				var apiErr *APIError
+				if errors.As(err, &apiErr) {
+					*seen = append(*seen, name+"<"+apiErr.Code)
+				} else {
+					*seen = append(*seen, name+"<ok")
+				}
+				return err
+			}
+		}
+	}
+	header := func(next RoundTrip) RoundTrip {
+		return func(c *Invocation) error {
+			c.Request.Header.Set("X-Request-Id", "id")
+			return next(c)
+		}
+	}
+	retry := func(next RoundTrip) RoundTrip {
+		return func(c *Invocation) error {
+			err := next(c)
+			if c.StatusCode >= 500 {
+				err = next(c)
+			}
+			return err
+		}
+	}
+
+	tests := []struct {
+		name  string
+		p     *SendParams
+		mws   func(*[]string) []Middleware
+		init  func(*mocks.HTTPClient)
+		after func(*Invocation, []string, error)
+	}{
+		{"in order", &SendParams{RouteName: "status"}, func(seen *[]string) []Middleware {
+			return []Middleware{record("a", seen), record("b", seen), header}
+		}, func(c *mocks.HTTPClient) {
+			c.EXPECT().Do(mock.Anything).Run(func(req *http.Request) {
+				assert.Equal("id", req.Header.Get("X-Request-Id"))
+			}).Return(newResponseOK(`{"message":"OK"}`), nil)
+		}, func(c *Invocation, seen []string, err error) {
+			require.NoError(err)
+			assert.Equal([]string{"a>status GET", "b>status GET", "b<ok", "a<ok"}, seen)
+			assert.Equal(http.StatusOK, c.StatusCode)
+			assert.Equal(1, c.Attempt)
+		}},
+		{"decoded error", &SendParams{RouteName: "status"}, func(seen *[]string) []Middleware {
+			return []Middleware{record("a", seen)}
+		}, func(c *mocks.HTTPClient) {
+			c.EXPECT().Do(mock.Anything).Return(newResponse(http.StatusBadRequest, `{"code":"INVALID_REQUEST_PARAMS"}`), nil)
+		}, func(c *Invocation, seen []string, err error) {
+			require.Error(err)
+			assert.Equal([]string{"a>status GET", "a<INVALID_REQUEST_PARAMS"}, seen)
+			assert.Equal(http.StatusBadRequest, c.StatusCode)
+		}},
+		{"retry", &SendParams{RouteName: "payment-create", Body: strings.NewReader(`{"a":1}`)}, func(seen *[]string) []Middleware {
+			return []Middleware{retry}
+		}, func(c *mocks.HTTPClient) {
+			c.EXPECT().Do(mock.Anything).Run(func(req *http.Request) {
+				var b bytes.Buffer
+				b.ReadFrom(req.Body)
+				assert.Equal(`{"a":1}`, b.String())
+			}).Return(newResponse(http.StatusBadGateway, `{}`), nil).Once()
+			c.EXPECT().Do(mock.Anything).Run(func(req *http.Request) {
+				var b bytes.Buffer
+				b.ReadFrom(req.Body)
+				assert.Equal(`{"a":1}`, b.String(), "body sent again")
+			}).Return(newResponseOK(`{}`), nil).Once()
+		}, func(c *Invocation, seen []string, err error) {
+			require.NoError(err)
+			assert.Equal(http.MethodPost, c.Method)
+			assert.Equal(2, c.Attempt)
+			assert.Equal(http.StatusOK, c.StatusCode)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ClearMiddleware()
+			c := mocks.NewHTTPClient(t)
+			UseClient(c)
+			tt.init(c)
+
+			var seen []string
+			var call *Invocation
+			UseMiddleware(func(next RoundTrip) RoundTrip {
+				return func(c *Invocation) error {
+					call = c
+					return next(c)
+				}
+			})
+			UseMiddleware(tt.mws(&seen)...)
+
+			tt.p.Into = &map[string]any{}
+			err := HTTPSend(tt.p)
+			require.NotNil(call)
+			assert.Equal(tt.p, call.Params)
+			assert.NotNil(call.Context)
+			tt.after(call, seen, err)
+		})
+	}
+}
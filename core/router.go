@@ -1,11 +1,14 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 
 	"github.com/CIDgravity/go-nowpayments/config"
 	"github.com/rotisserie/eris"
@@ -21,6 +24,8 @@ const (
 
 // SendParams are parameters needed to build and send an HTTP request to the service
 type SendParams struct {
+	// Context of the call, context.Background() when nil
+	Context   context.Context
 	Body      io.Reader
 	Into      interface{}
 	Path      string
@@ -30,59 +35,88 @@ type SendParams struct {
 }
 
 type routeAttr struct {
-	method string
-	path   string
+	method   string
+	path     string
+	envelope Envelope
 }
 
 // V2ResponseFormat handle some inconsistency on their side
 // some response are at root level, sometimes in data (list only) and sometimes under result key
-// because of many result format, we use generics to instantiate the struct with the correct response format
+//
+// Deprecated: the envelope of the responses is declared in the routes table and unwrapped by HTTPSend
 type V2ResponseFormat[T interface{}] struct {
 	Result T `json:"result"`
 }
 
+var routesMu sync.RWMutex
+
 var routes map[string]routeAttr = map[string]routeAttr{
-	"auth":   {http.MethodPost, "/auth"},
-	"status": {http.MethodGet, "/status"},
+	"auth":   {http.MethodPost, "/auth", NoEnvelope},
+	"status": {http.MethodGet, "/status", NoEnvelope},
 
 	// Currencies and estimation routes
-	"currencies": {http.MethodGet, "/currencies"},
-	"estimate":   {http.MethodGet, "/estimate"},
+	"currencies": {http.MethodGet, "/currencies", NoEnvelope},
+	"estimate":   {http.MethodGet, "/estimate", NoEnvelope},
 
 	// Payments routes
-	"invoice-create":      {http.MethodPost, "/invoice"},
-	"invoice-payment":     {http.MethodPost, "/invoice-payment"},
-	"last-estimate":       {http.MethodPost, "/payment"},
-	"min-amount":          {http.MethodGet, "/min-amount"},
-	"payment-create":      {http.MethodPost, "/payment"},
-	"payment-status":      {http.MethodGet, "/payment"},
-	"payments-list":       {http.MethodGet, "/payment/"},
-	"selected-currencies": {http.MethodGet, "/merchant/coins"},
+	"invoice-create":      {http.MethodPost, "/invoice", NoEnvelope},
+	"invoice-payment":     {http.MethodPost, "/invoice-payment", NoEnvelope},
+	"last-estimate":       {http.MethodPost, "/payment", NoEnvelope},
+	"min-amount":          {http.MethodGet, "/min-amount", NoEnvelope},
+	"payment-create":      {http.MethodPost, "/payment", NoEnvelope},
+	"payment-status":      {http.MethodGet, "/payment", NoEnvelope},
+	"payments-list":       {http.MethodGet, "/payment/", DataEnvelope},
+	"selected-currencies": {http.MethodGet, "/merchant/coins", NoEnvelope},
 
 	// Subscription routes
-	"subscription-create":       {http.MethodPost, "/subscriptions/plans"},
-	"subscription-update":       {http.MethodPatch, "/subscriptions/plans"},
-	"subscription-single":       {http.MethodGet, "/subscriptions/plans"},
-	"subscription-list":         {http.MethodGet, "/subscriptions/plans"},
-	"subscription-create-email": {http.MethodPost, "/subscriptions"},
+	"subscription-create":       {http.MethodPost, "/subscriptions/plans", ResultEnvelope},
+	"subscription-update":       {http.MethodPatch, "/subscriptions/plans", ResultEnvelope},
+	"subscription-single":       {http.MethodGet, "/subscriptions/plans", ResultEnvelope},
+	"subscription-list":         {http.MethodGet, "/subscriptions/plans", ResultEnvelope},
+	"subscription-create-email": {http.MethodPost, "/subscriptions", ResultEnvelope},
 
 	// Recurring payments routes
-	"recurring-payment-create": {http.MethodPost, "/subscriptions"},
-	"recurring-payment-single": {http.MethodGet, "/subscriptions"},
-	"recurring-payment-list":   {http.MethodGet, "/subscriptions"},
-	"recurring-payment-delete": {http.MethodDelete, "/subscriptions"},
+	"recurring-payment-create": {http.MethodPost, "/subscriptions", ResultEnvelope},
+	"recurring-payment-single": {http.MethodGet, "/subscriptions", ResultEnvelope},
+	"recurring-payment-list":   {http.MethodGet, "/subscriptions", ResultEnvelope},
+	"recurring-payment-delete": {http.MethodDelete, "/subscriptions", ResultEnvelope},
 
 	// Custody routes
-	"custody-create-account":       {http.MethodPost, "/sub-partner/balance"},
-	"custody-account-balance":      {http.MethodGet, "/sub-partner/balance"},
-	"custody-transfer-create":      {http.MethodPost, "/sub-partner/transfer"},
-	"custody-list-transfers":       {http.MethodGet, "/sub-partner/transfers"},
-	"custody-transfer-single":      {http.MethodGet, "/sub-partner/transfer"},
-	"custody-list-users":           {http.MethodGet, "/sub-partner"},
-	"custody-deposit-with-payment": {http.MethodPost, "/sub-partner/payment"},
-	"custody-deposit-from-master":  {http.MethodPost, "/sub-partner/deposit"},
-	"custody-payment-list":         {http.MethodGet, "/sub-partner/payments"},
-	"custody-write-off-to-master":  {http.MethodPost, "/sub-partner/write-off"},
+	"custody-create-account":       {http.MethodPost, "/sub-partner/balance", ResultEnvelope},
+	"custody-account-balance":      {http.MethodGet, "/sub-partner/balance", ResultEnvelope},
+	"custody-transfer-create":      {http.MethodPost, "/sub-partner/transfer", ResultEnvelope},
+	"custody-list-transfers":       {http.MethodGet, "/sub-partner/transfers", ResultEnvelope},
+	"custody-transfer-single":      {http.MethodGet, "/sub-partner/transfer", ResultEnvelope},
+	"custody-list-users":           {http.MethodGet, "/sub-partner", ResultEnvelope},
+	"custody-deposit-with-payment": {http.MethodPost, "/sub-partner/payment", ResultEnvelope},
+	"custody-deposit-from-master":  {http.MethodPost, "/sub-partner/deposit", ResultEnvelope},
+	"custody-payment-list":         {http.MethodGet, "/sub-partner/payments", ResultEnvelope},
+	"custody-write-off-to-master":  {http.MethodPost, "/sub-partner/write-off", ResultEnvelope},
+	"custody-conversion-create":    {http.MethodPost, "/conversion", ResultEnvelope},
+	"custody-conversion-single":    {http.MethodGet, "/conversion", ResultEnvelope},
+	"custody-conversion-list":      {http.MethodGet, "/conversion", ResultEnvelope},
+}
+
+// route returns the route of a name, with an empty path if unknown
+func route(name string) routeAttr {
+	routesMu.RLock()
+	defer routesMu.RUnlock()
+
+	return routes[name]
+}
+
+// RegisterRoute adds a route to call an endpoint not wrapped by the library with HTTPSend, or replaces one
+// The path is relative to the base URL, e.g. /payment, and env is the envelope of its responses
+func RegisterRoute(name, method, path string, env Envelope) error {
+	if name == "" || method == "" || !strings.HasPrefix(path, "/") {
+		return eris.Errorf("bad route %q: name, method and path starting with / are required", name)
+	}
+
+	routesMu.Lock()
+	defer routesMu.Unlock()
+
+	routes[name] = routeAttr{method: strings.ToUpper(method), path: path, envelope: env}
+	return nil
 }
 
 var (
@@ -91,7 +125,8 @@ var (
 
 var debug = false
 
-// WithDebug prints out debugging info about HTTP traffic
+// WithDebug logs the HTTP traffic to the standard error, credentials being redacted, when no logger is set
+// with UseLogger
 func WithDebug(d bool) {
 	debug = d
 }
@@ -102,16 +137,24 @@ func UseBaseURL(b BaseURL) {
 }
 
 // HTTPSend sends to endpoint with an optional request body and get the HTTP response result in into
+// The response is unwrapped from the envelope of the route, a single element array being unwrapped when into
+// isn't a slice. A *ShapeError is returned when the response doesn't have the shape of into
+// The call goes through the middlewares registered with UseMiddleware
 func HTTPSend(p *SendParams) error {
 	if p == nil {
 		return eris.New("nil params")
 	}
 
-	method, path := routes[p.RouteName].method, routes[p.RouteName].path
-	if path == "" {
+	r := route(p.RouteName)
+	if r.path == "" {
 		return eris.New(fmt.Sprintf("bad route name: empty path for endpoint %q", p.RouteName))
 	}
+	return do(p, r)
+}
 
+// do sends the request of a route
+func do(p *SendParams, r routeAttr) error {
+	method, path := r.method, r.path
 	u := string(defaultURL) + path
 	if p.Path != "" {
 		u += "/" + p.Path
@@ -121,13 +164,22 @@ func HTTPSend(p *SendParams) error {
 		u += "?" + p.Values.Encode()
 	}
 
-	req, err := http.NewRequest(method, u, p.Body)
+	ctx := p.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, p.Body)
 	if err != nil {
 		return eris.Wrap(err, p.RouteName)
 	}
 
 	// Extra headers
-	req.Header.Add("X-API-KEY", config.APIKey())
+	apiKey, err := config.ResolveAPIKey()
+	if err != nil {
+		return eris.Wrap(err, p.RouteName)
+	}
+	req.Header.Add("X-API-KEY", apiKey)
 	if p.Body != nil {
 		req.Header.Add("Content-Type", "application/json")
 	}
@@ -136,34 +188,29 @@ func HTTPSend(p *SendParams) error {
 		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", p.JWTToken))
 	}
 
-	// Debug mode
-	if debug {
-		fmt.Println(">>> DEBUG REQUEST")
-		fmt.Printf("X-API-KEY: %s\n", req.Header.Get("X-API-KEY"))
-		fmt.Printf("Authorization: %s\n", req.Header.Get("Authorization"))
-		fmt.Println(req.Method, req.URL.String())
-		fmt.Println("<<< END DEBUG REQUEST")
-	}
+	c := &Invocation{Context: ctx, RouteName: p.RouteName, Method: method, Params: p, Request: req, envelope: r.envelope}
+	return chain(roundTrip)(c)
+}
 
-	res, err := client.Do(req)
+// send executes the request and decodes its response, cl logging the call when not nil
+func send(c *Invocation, cl *callLog) error {
+	p := c.Params
+	res, err := client.Do(c.Request)
 	if err != nil {
 		return eris.Wrap(err, p.RouteName)
 	}
 
 	defer res.Body.Close()
+	c.StatusCode = res.StatusCode
+	if res.StatusCode == http.StatusTooManyRequests {
+		c.RateLimited++
+	}
+	if cl != nil {
+		cl.response(res)
+	}
 
 	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
-		if debug {
-			fmt.Printf(">>> DEBUG HTTP error %d: %s\n", res.StatusCode, res.Status)
-		}
-
-		type errResp struct {
-			StatusCode int    `json:"statusCode"`
-			Code       string `json:"code"`
-			Message    string `json:"message"`
-		}
-
-		z := &errResp{}
+		z := &APIError{HTTPStatus: res.StatusCode}
 		d := json.NewDecoder(res.Body)
 
 		err = d.Decode(&z)
@@ -171,23 +218,17 @@ func HTTPSend(p *SendParams) error {
 			return eris.Wrapf(err, "%s: JSON decode error", p.RouteName)
 		}
 
-		return eris.New(fmt.Sprintf("code %d (%s): %s", z.StatusCode, z.Code, z.Message))
+		return z
 	}
 
-	if debug {
-		fmt.Println(">>> DEBUG RAW RESPONSE BODY")
-
-		all, err := io.ReadAll(res.Body)
-		if err != nil {
-			return eris.Wrap(err, "debug response")
-		}
-
-		fmt.Println(string(all))
-		fmt.Println("<<< END DEBUG RAW RESPONSE BODY")
-		return eris.Wrap(json.Unmarshal(all, &p.Into), p.RouteName)
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return eris.Wrap(err, p.RouteName)
 	}
-
-	d := json.NewDecoder(res.Body)
-	err = d.Decode(&p.Into)
+	into := p.Into
+	if into == nil {
+		into = &p.Into
+	}
+	err = decodeEnvelope(p.RouteName, c.envelope, body, into)
 	return eris.Wrap(err, p.RouteName)
 }
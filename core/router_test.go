@@ -144,6 +144,11 @@ func TestHTTPSend(t *testing.T) {
 			},
 			func(p *SendParams, err error) {
 				assert.Equal("code 500 (server error): damn", err.Error())
+				var ae *APIError
+				if assert.True(errors.As(err, &ae)) {
+					assert.Equal(http.StatusInternalServerError, ae.HTTPStatus)
+					assert.True(ae.Temporary())
+				}
 			},
 		},
 		{"decode error status", &SendParams{RouteName: "status"}, true,
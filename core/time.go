@@ -0,0 +1,71 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// DateLayout is the layout of the dates sent to the endpoints expecting a day only
+	DateLayout = "2006-01-02"
+	// TimeLayout is the layout of the timestamps sent to the API, RFC3339 with milliseconds
+	TimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// timeLayouts are the layouts of the timestamps returned by the API
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	DateLayout,
+}
+
+// Time is a timestamp the API returns in various formats: RFC3339 with or without milliseconds or timezone,
+// UTC being assumed without, a date only, an empty string or null for no time
+type Time struct {
+	time.Time
+}
+
+// ParseTime parses a timestamp in any of the formats returned by the API, an empty string being the zero time
+func ParseTime(s string) (Time, error) {
+	if s == "" {
+		return Time{}, nil
+	}
+	for _, l := range timeLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return Time{t}, nil
+		}
+	}
+	return Time{}, fmt.Errorf("Time: unknown format %q", s)
+}
+
+// UnmarshalJSON accepts a JSON string in any of the formats returned by the API, or null
+func (t *Time) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		*t = Time{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("Time: expected string, got %s", b)
+	}
+	v, err := ParseTime(s)
+	if err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
+// MarshalJSON encodes the time with TimeLayout, or null when zero
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Format(TimeLayout))
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTime(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	type payment struct {
+		CreatedAt Time `json:"created_at"`
+	}
+
+	at := time.Date(2023, 5, 1, 12, 30, 15, 0, time.UTC)
+	tests := []struct {
+		name    string
+		body    string
+		want    time.Time
+		wantErr bool
+	}{
+		{"milliseconds", `{"created_at":"2023-05-01T12:30:15.000Z"}`, at, false},
+		{"seconds", `{"created_at":"2023-05-01T12:30:15Z"}`, at, false},
+		{"offset", `{"created_at":"2023-05-01T14:30:15+02:00"}`, at, false},
+		{"no timezone", `{"created_at":"2023-05-01T12:30:15.000"}`, at, false},
+		{"space", `{"created_at":"2023-05-01 12:30:15"}`, at, false},
+		{"date", `{"created_at":"2023-05-01"}`, time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC), false},
+		{"empty", `{"created_at":""}`, time.Time{}, false},
+		{"null", `{"created_at":null}`, time.Time{}, false},
+		{"missing", `{}`, time.Time{}, false},
+		{"bad format", `{"created_at":"01/05/2023"}`, time.Time{}, true},
+		{"number", `{"created_at":1682944215}`, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p payment
+			err := json.Unmarshal([]byte(tt.body), &p)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+			assert.True(tt.want.Equal(p.CreatedAt.Time), "got %s", p.CreatedAt)
+		})
+	}
+
+	data, err := json.Marshal(payment{Time{at}})
+	require.NoError(err)
+	assert.Equal(`{"created_at":"2023-05-01T12:30:15.000Z"}`, string(data))
+
+	data, err = json.Marshal(payment{})
+	require.NoError(err)
+	assert.Equal(`{"created_at":null}`, string(data))
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ID is an identifier the API returns either as a JSON string or number, e.g. payment_id
+// It is encoded as a JSON number when it is an integer, null when empty, and as a JSON string otherwise
+type ID string
+
+// MarshalJSON encodes the ID like NOWPayments does, so that the signature of an encoded IPN payload matches
+func (id ID) MarshalJSON() ([]byte, error) {
+	switch {
+	case id == "":
+		return []byte("null"), nil
+	case id.numeric():
+		return []byte(id), nil
+	}
+	return json.Marshal(string(id))
+}
+
+// UnmarshalJSON accepts a JSON string, number or null
+func (id *ID) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	switch {
+	case bytes.Equal(b, []byte("null")):
+		*id = ""
+	case len(b) > 0 && b[0] == '"':
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*id = ID(s)
+	default:
+		var n json.Number
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("ID: expected string or number, got %s", b)
+		}
+		*id = ID(n)
+	}
+	return nil
+}
+
+// numeric tells if the ID is an integer written without leading zeros, which is kept when encoded as a number
+func (id ID) numeric() bool {
+	s := string(id)
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	}
+	if s == "" || (s[0] == '0' && len(s) > 1) {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the ID
+func (id ID) String() string {
+	return string(id)
+}
+
+// Int64 returns the ID as a number, for the APIs expecting one
+func (id ID) Int64() (int64, error) {
+	return strconv.ParseInt(string(id), 10, 64)
+}
+
+// Float is a number the API returns either as a JSON number or string, e.g. pay_amount
+// It is encoded as a JSON number
+type Float float64
+
+// UnmarshalJSON accepts a JSON number, string holding a number, empty string or null
+func (f *Float) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		*f = 0
+		return nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			*f = 0
+			return nil
+		}
+		b = []byte(s)
+	}
+	v, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return fmt.Errorf("Float: expected number, got %s", b)
+	}
+	*f = Float(v)
+	return nil
+}
+
+// Float64 returns the number as a float64
+func (f Float) Float64() float64 {
+	return float64(f)
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlexibleTypes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	type payment struct {
+		ID     ID    `json:"payment_id"`
+		Amount Float `json:"pay_amount"`
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    payment
+		wantErr bool
+	}{
+		{"numbers", `{"payment_id":5077125051,"pay_amount":0.00033}`, payment{"5077125051", 0.00033}, false},
+		{"strings", `{"payment_id":"5077125051","pay_amount":"0.00033"}`, payment{"5077125051", 0.00033}, false},
+		{"nulls", `{"payment_id":null,"pay_amount":null}`, payment{}, false},
+		{"empty amount", `{"pay_amount":""}`, payment{}, false},
+		{"bad ID", `{"payment_id":true}`, payment{}, true},
+		{"bad amount", `{"pay_amount":"abc"}`, payment{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p payment
+			err := json.Unmarshal([]byte(tt.body), &p)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tt.want, p)
+		})
+	}
+
+	for id, want := range map[ID]string{"12": `12`, "": `null`, "0": `0`, "007": `"007"`, "ab12": `"ab12"`, "1e3": `"1e3"`} {
+		data, err := json.Marshal(payment{id, 1.5})
+		require.NoError(err)
+		assert.Equal(`{"payment_id":`+want+`,"pay_amount":1.5}`, string(data), "ID %q", id)
+	}
+
+	n, err := ID("12").Int64()
+	require.NoError(err)
+	assert.Equal(int64(12), n)
+}
@@ -0,0 +1,172 @@
+package custody
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/rotisserie/eris"
+)
+
+// ConversionStatus is the status of a currency conversion, as documented by the API
+type ConversionStatus string
+
+const (
+	ConversionWaiting    ConversionStatus = "WAITING"
+	ConversionProcessing ConversionStatus = "PROCESSING"
+	ConversionFinished   ConversionStatus = "FINISHED"
+	ConversionRejected   ConversionStatus = "REJECTED"
+)
+
+// ConversionArgs are the arguments used to convert the custody balance from one currency to another
+// Conversions apply to the master account balance, the API has no sub-partner parameter for them:
+// a sub-partner balance is converted by writing it off to master, converting and transferring it back
+type ConversionArgs struct {
+	Amount       float64 `json:"amount"`
+	FromCurrency string  `json:"from_currency"`
+	ToCurrency   string  `json:"to_currency"`
+}
+
+// ListConversionsOptionArgs are options applying to the list of conversions
+type ListConversionsOptionArgs struct {
+	ListCommonOptionsArgs
+	Status       string
+	FromCurrency string
+	ToCurrency   string
+	DateFrom     time.Time // creation time, zero meaning no filter
+	DateTo       time.Time // creation time, zero meaning no filter
+}
+
+// Conversion holds a currency conversion
+type Conversion struct {
+	ID           string           `json:"id,omitempty"`
+	Status       ConversionStatus `json:"status,omitempty"`
+	FromCurrency string           `json:"from_currency,omitempty"`
+	ToCurrency   string           `json:"to_currency,omitempty"`
+	FromAmount   string           `json:"from_amount,omitempty"`
+	ToAmount     string           `json:"to_amount,omitempty"`
+	CreatedAt    core.Time        `json:"created_at,omitempty"`
+	UpdatedAt    core.Time        `json:"updated_at,omitempty"`
+}
+
+// NewConversion will convert the custody balance from one currency to another
+// The amount received can be estimated beforehand with payments.EstimatedPrice
+// JWT is required for this request
+func NewConversion(ca *ConversionArgs) (*Conversion, error) {
+	if ca == nil {
+		return nil, errors.New("nil conversion args")
+	}
+
+	d, err := json.Marshal(ca)
+	if err != nil {
+		return nil, eris.Wrap(err, "conversion args")
+	}
+
+	tok, err := core.AuthenticateFromConfig()
+	if err != nil {
+		return nil, eris.Wrap(err, "conversion")
+	}
+
+	cv := &Conversion{}
+	par := &core.SendParams{
+		RouteName: "custody-conversion-create",
+		Into:      cv,
+		Body:      strings.NewReader(string(d)),
+		JWTToken:  tok,
+	}
+
+	err = core.HTTPSend(par)
+	if err != nil {
+		return nil, err
+	}
+
+	return cv, nil
+}
+
+// GetConversion will return single conversion information based on the supplied conversion ID
+// JWT is required for this request
+func GetConversion(conversionID string) (*Conversion, error) {
+	if conversionID == "" {
+		return nil, eris.New("empty conversion ID")
+	}
+
+	tok, err := core.AuthenticateFromConfig()
+	if err != nil {
+		return nil, eris.Wrap(err, "conversion")
+	}
+
+	cv := &Conversion{}
+	par := &core.SendParams{
+		RouteName: "custody-conversion-single",
+		Path:      conversionID,
+		Into:      cv,
+		JWTToken:  tok,
+	}
+
+	err = core.HTTPSend(par)
+	if err != nil {
+		return nil, err
+	}
+
+	return cv, nil
+}
+
+// ListConversions return a list of all conversions based on supplied options (which can be nil)
+// JWT is required for this request
+func ListConversions(o *ListConversionsOptionArgs) ([]*Conversion, error) {
+	u := url.Values{}
+
+	if o != nil {
+		if o.Id != 0 {
+			u.Set("id", fmt.Sprintf("%d", o.Id))
+		}
+		if o.Status != "" {
+			u.Set("status", o.Status)
+		}
+		if o.FromCurrency != "" {
+			u.Set("from_currency", o.FromCurrency)
+		}
+		if o.ToCurrency != "" {
+			u.Set("to_currency", o.ToCurrency)
+		}
+		if !o.DateFrom.IsZero() {
+			u.Set("created_at_from", o.DateFrom.UTC().Format(core.TimeLayout))
+		}
+		if !o.DateTo.IsZero() {
+			u.Set("created_at_to", o.DateTo.UTC().Format(core.TimeLayout))
+		}
+		if o.Limit != 0 {
+			u.Set("limit", fmt.Sprintf("%d", o.Limit))
+		}
+		if o.Offset != 0 {
+			u.Set("offset", fmt.Sprintf("%d", o.Offset))
+		}
+		if o.Order != "" {
+			u.Set("order", o.Order)
+		}
+	}
+
+	tok, err := core.AuthenticateFromConfig()
+	if err != nil {
+		return nil, eris.Wrap(err, "list conversions")
+	}
+
+	cvl := make([]*Conversion, 0)
+	par := &core.SendParams{
+		RouteName: "custody-conversion-list",
+		Into:      &cvl,
+		Values:    u,
+		JWTToken:  tok,
+	}
+
+	err = core.HTTPSend(par)
+	if err != nil {
+		return nil, err
+	}
+
+	return cvl, nil
+}
@@ -0,0 +1,102 @@
+package custody
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewConversion(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		name  string
+		ca    *ConversionArgs
+		init  func(*mocks.HTTPClient)
+		after func(*Conversion, error)
+	}{
+		{"nil args", nil, nil,
+			func(cv *Conversion, err error) {
+				assert.Nil(cv)
+				assert.Error(err)
+			},
+		},
+		{"route, body and response", &ConversionArgs{Amount: 50, FromCurrency: "usdtbsc", ToCurrency: "usdttrc20"},
+			func(c *mocks.HTTPClient) {
+				c.EXPECT().Do(mock.Anything).Call.Return(
+					func(req *http.Request) *http.Response {
+						switch req.URL.Path {
+						case "/v1/auth":
+							return newResponseOK(`{"token":"tok"}`)
+						case "/v1/conversion":
+							assert.Equal(http.MethodPost, req.Method)
+							assert.Equal("Bearer tok", req.Header.Get("Authorization"))
+							body, _ := io.ReadAll(req.Body)
+							assert.JSONEq(`{"amount":50,"from_currency":"usdtbsc","to_currency":"usdttrc20"}`, string(body))
+							return newResponseOK(`{"result":{"id":"C1","status":"WAITING","from_amount":"50"}}`)
+						}
+						return nil
+					}, nil)
+			},
+			func(cv *Conversion, err error) {
+				assert.NoError(err)
+				if assert.NotNil(cv) {
+					assert.Equal("C1", cv.ID)
+					assert.Equal(ConversionWaiting, cv.Status)
+				}
+			},
+		},
+		{"auth fail", &ConversionArgs{},
+			func(c *mocks.HTTPClient) {
+				c.EXPECT().Do(mock.Anything).Return(nil, errors.New("bad credentials"))
+			},
+			func(cv *Conversion, err error) {
+				assert.Nil(cv)
+				assert.Equal("conversion: auth: bad credentials", err.Error())
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mocks.NewHTTPClient(t)
+			core.UseClient(c)
+			if tt.init != nil {
+				tt.init(c)
+			}
+			got, err := NewConversion(tt.ca)
+			if tt.after != nil {
+				tt.after(got, err)
+			}
+		})
+	}
+}
+
+func TestListConversions(t *testing.T) {
+	assert := assert.New(t)
+	c := mocks.NewHTTPClient(t)
+	core.UseClient(c)
+	c.EXPECT().Do(mock.Anything).Call.Return(
+		func(req *http.Request) *http.Response {
+			switch req.URL.Path {
+			case "/v1/auth":
+				return newResponseOK(`{"token":"tok"}`)
+			case "/v1/conversion":
+				assert.Equal("from_currency=usdtbsc&limit=5&status=FINISHED", req.URL.RawQuery)
+				return newResponseOK(`{"result":[{"id":"C1"},{"id":"C2"}]}`)
+			}
+			return nil
+		}, nil)
+
+	cvs, err := ListConversions(&ListConversionsOptionArgs{
+		ListCommonOptionsArgs: ListCommonOptionsArgs{Limit: 5},
+		Status:                "FINISHED",
+		FromCurrency:          "usdtbsc",
+	})
+	assert.NoError(err)
+	assert.Len(cvs, 2)
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"strings"
 
-	"github.com/CIDgravity/go-nowpayments/config"
 	"github.com/CIDgravity/go-nowpayments/core"
 	"github.com/CIDgravity/go-nowpayments/payments"
 	"github.com/rotisserie/eris"
@@ -27,7 +26,7 @@ type DepositWithPaymentArgs struct {
 // NewDepositWithPayment will create a payment to deposit on a specific user account (refill account)
 // The response doesn't provide the payment link, but can be built using https://nowpayments.io/payment/?iid=[INVOICE_ID]&paymentId=[PAYMENT_id]
 // JWT is required for this request
-func NewDepositWithPayment(da *DepositWithPaymentArgs) (*payments.Payment[string], error) {
+func NewDepositWithPayment(da *DepositWithPaymentArgs) (*payments.Payment, error) {
 	if da == nil {
 		return nil, errors.New("nil deposit args")
 	}
@@ -37,15 +36,15 @@ func NewDepositWithPayment(da *DepositWithPaymentArgs) (*payments.Payment[string
 		return nil, eris.Wrap(err, "deposit args")
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "deposit with payment")
 	}
 
-	dp := &core.V2ResponseFormat[*payments.Payment[string]]{}
+	dp := &payments.Payment{}
 	par := &core.SendParams{
 		RouteName: "custody-deposit-with-payment",
-		Into:      &dp,
+		Into:      dp,
 		Body:      strings.NewReader(string(d)),
 		JWTToken:  tok,
 	}
@@ -55,7 +54,7 @@ func NewDepositWithPayment(da *DepositWithPaymentArgs) (*payments.Payment[string
 		return nil, err
 	}
 
-	return dp.Result, nil
+	return dp, nil
 }
 
 // NewDepositFroMasterAccount will create a deposit on a specific user account from a master account (no payment link, will use balance from master)
@@ -70,15 +69,15 @@ func NewDepositFroMasterAccount(da *DepositArgs) (*Transfer, error) {
 		return nil, eris.Wrap(err, "deposit args")
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "deposit from master account")
 	}
 
-	tr := &core.V2ResponseFormat[*Transfer]{}
+	tr := &Transfer{}
 	par := &core.SendParams{
 		RouteName: "custody-deposit-from-master",
-		Into:      &tr,
+		Into:      tr,
 		Body:      strings.NewReader(string(d)),
 		JWTToken:  tok,
 	}
@@ -88,5 +87,5 @@ func NewDepositFroMasterAccount(da *DepositArgs) (*Transfer, error) {
 		return nil, err
 	}
 
-	return tr.Result, nil
+	return tr, nil
 }
@@ -0,0 +1,322 @@
+package custody
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/payments"
+	"github.com/rotisserie/eris"
+)
+
+const (
+	defaultLedgerPageSize  = 100
+	defaultLedgerTolerance = 1e-8
+)
+
+// LedgerEntryKind is the kind of movement recorded in a ledger entry
+type LedgerEntryKind string
+
+const (
+	LedgerDeposit       LedgerEntryKind = "deposit"
+	LedgerMasterDeposit LedgerEntryKind = "master-deposit"
+	LedgerTransferIn    LedgerEntryKind = "transfer-in"
+	LedgerTransferOut   LedgerEntryKind = "transfer-out"
+	LedgerWriteOff      LedgerEntryKind = "write-off"
+)
+
+// LedgerOptions are options applying to the ledger reconstruction (which can be nil)
+type LedgerOptions struct {
+	// MasterID is the master account ID, used to tell deposits from master and write-offs apart from transfers
+	MasterID string
+	// PageSize is the number of payments or transfers fetched per call (default 100)
+	PageSize int64
+	// Tolerance is the maximum difference allowed between the ledger and the balance (default 1e-8)
+	Tolerance float64
+}
+
+// LedgerEntry is a single movement on a Custody user account
+// Amount is signed: positive when funds are credited, negative when they are debited
+// Balance is the running settled balance of the entry currency, after this entry
+type LedgerEntry struct {
+	Kind      LedgerEntryKind
+	Reference string
+	Currency  string
+	Amount    float64
+	Status    string
+	Settled   bool
+	CreatedAt time.Time
+	Balance   float64
+}
+
+// Ledger is the chronological list of movements of a Custody user account
+type Ledger struct {
+	SubPartnerID string
+	Entries      []*LedgerEntry
+	// Settled and Pending are the ledger totals per currency
+	Settled map[string]float64
+	Pending map[string]float64
+
+	tolerance float64
+}
+
+// Discrepancy is a mismatch between the ledger and the balance returned by the API for a currency
+// Entries are the pending entries of this currency, which are the usual explanation of a mismatch
+// (all entries of the currency are listed when none is pending)
+type Discrepancy struct {
+	Currency       string
+	LedgerAmount   float64
+	BalanceAmount  float64
+	LedgerPending  float64
+	BalancePending float64
+	Entries        []*LedgerEntry
+}
+
+// Reconciliation holds the result of comparing a ledger against the balance returned by the API
+type Reconciliation struct {
+	SubPartnerID  string
+	Balances      *UserBalances
+	Discrepancies []*Discrepancy
+}
+
+// OK returns true if no discrepancy was found
+func (r *Reconciliation) OK() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// NewLedger rebuilds the ledger of a Custody user account from its deposits (ListPayments) and its
+// transfers in and out, including deposits from master and write-offs (ListTransfers)
+// JWT is required for this request
+func NewLedger(subPartnerID string, o *LedgerOptions) (*Ledger, error) {
+	if subPartnerID == "" {
+		return nil, eris.New("empty sub partner ID")
+	}
+
+	if o == nil {
+		o = &LedgerOptions{}
+	}
+
+	limit := o.PageSize
+	if limit <= 0 {
+		limit = defaultLedgerPageSize
+	}
+
+	var ps []*payments.Payment
+	for page := int64(0); ; page++ {
+		pp, err := ListPayments(&ListPaymentsOption{SubPartnerID: subPartnerID, Limit: limit, Page: page})
+		if err != nil {
+			return nil, eris.Wrap(err, "ledger")
+		}
+
+		ps = append(ps, pp...)
+		if int64(len(pp)) < limit {
+			break
+		}
+	}
+
+	var ts []*Transfer
+	for offset := int64(0); ; offset += limit {
+		tp, err := ListTransfers(&ListTransfersOptionArgs{ListCommonOptionsArgs: ListCommonOptionsArgs{Limit: limit, Offset: offset}})
+		if err != nil {
+			return nil, eris.Wrap(err, "ledger")
+		}
+
+		ts = append(ts, tp...)
+		if int64(len(tp)) < limit {
+			break
+		}
+	}
+
+	return BuildLedger(subPartnerID, ps, ts, o)
+}
+
+// BuildLedger builds the ledger of a Custody user account from already fetched payments and transfers
+// Transfers not involving the user account are ignored, as well as failed payments and rejected transfers
+func BuildLedger(subPartnerID string, ps []*payments.Payment, ts []*Transfer, o *LedgerOptions) (*Ledger, error) {
+	if o == nil {
+		o = &LedgerOptions{}
+	}
+
+	l := &Ledger{
+		SubPartnerID: subPartnerID,
+		Settled:      make(map[string]float64),
+		Pending:      make(map[string]float64),
+		tolerance:    o.Tolerance,
+	}
+	if l.tolerance <= 0 {
+		l.tolerance = defaultLedgerTolerance
+	}
+
+	for _, p := range ps {
+		if p == nil {
+			continue
+		}
+
+		settled, ok := paymentSettlement(p.Status)
+		if !ok {
+			continue
+		}
+
+		l.Entries = append(l.Entries, &LedgerEntry{
+			Kind:      LedgerDeposit,
+			Reference: p.ID.String(),
+			Currency:  strings.ToLower(p.PayCurrency),
+			Amount:    p.ActuallyPaid.Float64(),
+			Status:    p.Status,
+			Settled:   settled,
+			CreatedAt: p.CreatedAt.Time,
+		})
+	}
+
+	for _, t := range ts {
+		if t == nil || (t.FromSubID != subPartnerID && t.ToSubID != subPartnerID) {
+			continue
+		}
+
+		if t.Status.IsFinal() && !t.Status.IsSuccess() {
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(t.Amount, 64)
+		if err != nil {
+			return nil, eris.Wrapf(err, "ledger: transfer %s amount", t.Id)
+		}
+
+		e := &LedgerEntry{
+			Reference: t.Id,
+			Currency:  strings.ToLower(t.Currency),
+			Status:    string(t.Status),
+			Settled:   t.Status.IsSuccess(),
+			CreatedAt: t.CreatedAt.Time,
+		}
+
+		switch {
+		case t.ToSubID == subPartnerID && o.MasterID != "" && t.FromSubID == o.MasterID:
+			e.Kind, e.Amount = LedgerMasterDeposit, amount
+		case t.ToSubID == subPartnerID:
+			e.Kind, e.Amount = LedgerTransferIn, amount
+		case o.MasterID != "" && t.ToSubID == o.MasterID:
+			e.Kind, e.Amount = LedgerWriteOff, -amount
+		default:
+			e.Kind, e.Amount = LedgerTransferOut, -amount
+		}
+
+		l.Entries = append(l.Entries, e)
+	}
+
+	sort.SliceStable(l.Entries, func(i, j int) bool {
+		return l.Entries[i].CreatedAt.Before(l.Entries[j].CreatedAt)
+	})
+
+	for _, e := range l.Entries {
+		if e.Settled {
+			l.Settled[e.Currency] += e.Amount
+		} else {
+			l.Pending[e.Currency] += e.Amount
+		}
+		e.Balance = l.Settled[e.Currency]
+	}
+
+	return l, nil
+}
+
+// Reconcile compares the ledger against the balance of the Custody user account returned by GetBalance
+func (l *Ledger) Reconcile() (*Reconciliation, error) {
+	b, err := GetBalance(l.SubPartnerID)
+	if err != nil {
+		return nil, eris.Wrap(err, "reconcile")
+	}
+
+	return l.ReconcileWith(b), nil
+}
+
+// ReconcileWith compares the ledger against already fetched balances
+func (l *Ledger) ReconcileWith(b *UserBalances) *Reconciliation {
+	r := &Reconciliation{
+		SubPartnerID: l.SubPartnerID,
+		Balances:     b,
+	}
+
+	// currencies are compared lower-cased, as in the ledger entries
+	balances := map[string]BalanceAmounts{}
+	if b != nil {
+		for c, am := range b.Balances {
+			c = strings.ToLower(c)
+			t := balances[c]
+			t.Amount += am.Amount
+			t.PendingAmount += am.PendingAmount
+			balances[c] = t
+		}
+	}
+
+	currencies := map[string]struct{}{}
+	for c := range balances {
+		currencies[c] = struct{}{}
+	}
+	for c := range l.Settled {
+		currencies[c] = struct{}{}
+	}
+	for c := range l.Pending {
+		currencies[c] = struct{}{}
+	}
+
+	names := make([]string, 0, len(currencies))
+	for c := range currencies {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+
+	for _, c := range names {
+		d := &Discrepancy{
+			Currency:       c,
+			LedgerAmount:   l.Settled[c],
+			BalanceAmount:  balances[c].Amount,
+			LedgerPending:  l.Pending[c],
+			BalancePending: balances[c].PendingAmount,
+		}
+
+		if math.Abs(d.LedgerAmount-d.BalanceAmount) <= l.tolerance &&
+			math.Abs(d.LedgerPending-d.BalancePending) <= l.tolerance {
+			continue
+		}
+
+		d.Entries = l.explain(c)
+		r.Discrepancies = append(r.Discrepancies, d)
+	}
+
+	return r
+}
+
+// explain returns the pending entries of a currency, or all its entries if none is pending
+func (l *Ledger) explain(currency string) []*LedgerEntry {
+	var all, pending []*LedgerEntry
+	for _, e := range l.Entries {
+		if e.Currency != currency {
+			continue
+		}
+		all = append(all, e)
+		if !e.Settled {
+			pending = append(pending, e)
+		}
+	}
+
+	if len(pending) > 0 {
+		return pending
+	}
+	return all
+}
+
+// paymentSettlement tells if a payment status credits the balance (settled) or is still in progress
+// ok is false for payments that never credit the balance
+func paymentSettlement(status string) (settled, ok bool) {
+	switch strings.ToLower(status) {
+	case "finished":
+		return true, true
+	case "failed", "refunded", "expired":
+		return false, false
+	default:
+		return false, true
+	}
+}
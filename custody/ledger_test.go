@@ -0,0 +1,94 @@
+package custody
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/payments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildLedger(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	day := func(d int) core.Time {
+		return core.Time{Time: time.Date(2023, 5, d, 0, 0, 0, 0, time.UTC)}
+	}
+
+	ps := []*payments.Payment{
+		{ID: "p1", Status: "finished", PayCurrency: "USDTBSC", ActuallyPaid: 10, CreatedAt: day(1)},
+		{ID: "p2", Status: "confirming", PayCurrency: "usdtbsc", ActuallyPaid: 4, CreatedAt: day(5)},
+		{ID: "p3", Status: "expired", PayCurrency: "usdtbsc", CreatedAt: day(6)},
+	}
+	ts := []*Transfer{
+		{Id: "t1", FromSubID: "master", ToSubID: "42", Status: "FINISHED", Amount: "5", Currency: "usdtbsc", CreatedAt: day(2)},
+		{Id: "t2", FromSubID: "42", ToSubID: "43", Status: "FINISHED", Amount: "3", Currency: "usdtbsc", CreatedAt: day(3)},
+		{Id: "t3", FromSubID: "42", ToSubID: "master", Status: "FINISHED", Amount: "2", Currency: "usdtbsc", CreatedAt: day(4)},
+		{Id: "t4", FromSubID: "43", ToSubID: "42", Status: "REJECTED", Amount: "100", Currency: "usdtbsc", CreatedAt: day(4)},
+		{Id: "t5", FromSubID: "43", ToSubID: "44", Status: "FINISHED", Amount: "100", Currency: "usdtbsc", CreatedAt: day(4)},
+	}
+
+	l, err := BuildLedger("42", ps, ts, &LedgerOptions{MasterID: "master"})
+	require.NoError(err)
+	require.Len(l.Entries, 5)
+
+	kinds := []LedgerEntryKind{}
+	balances := []float64{}
+	for _, e := range l.Entries {
+		kinds = append(kinds, e.Kind)
+		balances = append(balances, e.Balance)
+	}
+	assert.Equal([]LedgerEntryKind{LedgerDeposit, LedgerMasterDeposit, LedgerTransferOut, LedgerWriteOff, LedgerDeposit}, kinds)
+	assert.Equal([]float64{10, 15, 12, 10, 10}, balances)
+	assert.Equal(10.0, l.Settled["usdtbsc"])
+	assert.Equal(4.0, l.Pending["usdtbsc"])
+
+	t.Run("balance matches", func(t *testing.T) {
+		r := l.ReconcileWith(&UserBalances{
+			SubPartnerID: "42",
+			Balances:     Balances{"usdtbsc": {Amount: 10, PendingAmount: 4}},
+		})
+		assert.True(r.OK())
+	})
+
+	t.Run("every currency is reconciled", func(t *testing.T) {
+		ml, err := BuildLedger("42", []*payments.Payment{
+			{ID: "p4", Status: "finished", PayCurrency: "BTC", ActuallyPaid: 0.5, CreatedAt: day(1)},
+			{ID: "p5", Status: "finished", PayCurrency: "usdtbsc", ActuallyPaid: 10, CreatedAt: day(2)},
+		}, nil, nil)
+		require.NoError(err)
+
+		r := ml.ReconcileWith(&UserBalances{
+			SubPartnerID: "42",
+			Balances: Balances{
+				"btc":     {Amount: 0.5},
+				"usdtbsc": {Amount: 10},
+				"eth":     {Amount: 1},
+			},
+		})
+		if assert.Len(r.Discrepancies, 1) {
+			assert.Equal("eth", r.Discrepancies[0].Currency)
+			assert.Equal(1.0, r.Discrepancies[0].BalanceAmount)
+		}
+	})
+
+	t.Run("balance mismatch", func(t *testing.T) {
+		r := l.ReconcileWith(&UserBalances{
+			SubPartnerID: "42",
+			Balances:     Balances{"usdtbsc": {Amount: 14}},
+		})
+		assert.False(r.OK())
+		if assert.Len(r.Discrepancies, 1) {
+			d := r.Discrepancies[0]
+			assert.Equal("usdtbsc", d.Currency)
+			assert.Equal(10.0, d.LedgerAmount)
+			assert.Equal(14.0, d.BalanceAmount)
+			if assert.Len(d.Entries, 1) {
+				assert.Equal("p2", d.Entries[0].Reference)
+			}
+		}
+	})
+}
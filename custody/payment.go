@@ -3,8 +3,8 @@ package custody
 import (
 	"fmt"
 	"net/url"
+	"time"
 
-	"github.com/CIDgravity/go-nowpayments/config"
 	"github.com/CIDgravity/go-nowpayments/core"
 	"github.com/CIDgravity/go-nowpayments/payments"
 	"github.com/rotisserie/eris"
@@ -18,15 +18,15 @@ type ListPaymentsOption struct {
 	PayCurrency  string
 	Status       string
 	SubPartnerID string
-	DateFrom     string
-	DateTo       string
+	DateFrom     time.Time // creation day, as the calendar date of the time in its location, zero meaning no filter
+	DateTo       time.Time // creation day, as the calendar date of the time in its location, zero meaning no filter
 	OrderBy      string
 	SortBy       string
 }
 
 // ListPayments return all Custody Payments, based on provided filters (which can be nil)
 // JWT is required for this request
-func ListPayments(o *ListPaymentsOption) ([]*payments.Payment[string], error) {
+func ListPayments(o *ListPaymentsOption) ([]*payments.Payment, error) {
 	u := url.Values{}
 
 	if o != nil {
@@ -46,11 +46,11 @@ func ListPayments(o *ListPaymentsOption) ([]*payments.Payment[string], error) {
 		if o.SubPartnerID != "" {
 			u.Set("sub_partner_id", o.SubPartnerID)
 		}
-		if o.DateFrom != "" {
-			u.Set("date_from", o.DateFrom)
+		if !o.DateFrom.IsZero() {
+			u.Set("date_from", o.DateFrom.Format(core.DateLayout))
 		}
-		if o.DateTo != "" {
-			u.Set("date_to", o.DateTo)
+		if !o.DateTo.IsZero() {
+			u.Set("date_to", o.DateTo.Format(core.DateLayout))
 		}
 		if o.SortBy != "" {
 			u.Set("sort_by", o.SortBy)
@@ -60,15 +60,15 @@ func ListPayments(o *ListPaymentsOption) ([]*payments.Payment[string], error) {
 		}
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "list payments")
 	}
 
-	pal := &core.V2ResponseFormat[[]*payments.Payment[string]]{}
+	pal := make([]*payments.Payment, 0)
 	par := &core.SendParams{
 		RouteName: "custody-payment-list",
-		Into:      pal,
+		Into:      &pal,
 		Values:    u,
 		JWTToken:  tok,
 	}
@@ -78,5 +78,5 @@ func ListPayments(o *ListPaymentsOption) ([]*payments.Payment[string], error) {
 		return nil, err
 	}
 
-	return pal.Result, nil
+	return pal, nil
 }
@@ -1,14 +1,15 @@
 package custody
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
-	"github.com/CIDgravity/go-nowpayments/config"
 	"github.com/CIDgravity/go-nowpayments/core"
 	"github.com/rotisserie/eris"
 )
@@ -26,15 +27,65 @@ type TransferArgs struct {
 	Currency string  `json:"currency"`
 }
 
+// TransferStatus is the status of a transfer, deposit from master or write-off
+type TransferStatus string
+
+const (
+	TransferCreated  TransferStatus = "CREATED"
+	TransferWaiting  TransferStatus = "WAITING"
+	TransferFinished TransferStatus = "FINISHED"
+	TransferRejected TransferStatus = "REJECTED"
+	TransferFailed   TransferStatus = "FAILED"
+)
+
+// normalize returns the status in upper case, as the API is not consistent on the case used
+func (s TransferStatus) normalize() TransferStatus {
+	return TransferStatus(strings.ToUpper(string(s)))
+}
+
+// IsFinal returns true if the transfer will not change anymore
+func (s TransferStatus) IsFinal() bool {
+	switch s.normalize() {
+	case TransferFinished, TransferRejected, TransferFailed:
+		return true
+	}
+	return false
+}
+
+// IsSuccess returns true if the funds have been moved
+func (s TransferStatus) IsSuccess() bool {
+	return s.normalize() == TransferFinished
+}
+
 type Transfer struct {
-	Id        string    `json:"id,omitempty"`
-	FromSubID string    `json:"from_sub_id,omitempty"`
-	ToSubID   string    `json:"to_sub_id,omitempty"`
-	Status    string    `json:"status,omitempty"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
-	UpdatedAt time.Time `json:"updated_at,omitempty"`
-	Amount    string    `json:"amount,omitempty"`
-	Currency  string    `json:"currency,omitempty"`
+	Id        string         `json:"id,omitempty"`
+	FromSubID string         `json:"from_sub_id,omitempty"`
+	ToSubID   string         `json:"to_sub_id,omitempty"`
+	Status    TransferStatus `json:"status,omitempty"`
+	CreatedAt core.Time      `json:"created_at,omitempty"`
+	UpdatedAt core.Time      `json:"updated_at,omitempty"`
+	Amount    string         `json:"amount,omitempty"`
+	Currency  string         `json:"currency,omitempty"`
+
+	// Extra holds the fields unknown to the library, encoded along with the others
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps the unknown fields in Extra
+func (t *Transfer) UnmarshalJSON(data []byte) error {
+	type transfer Transfer
+	extra, err := core.DecodeExtra(data, (*transfer)(t))
+	if err != nil {
+		return err
+	}
+	t.Extra = extra
+	return nil
+}
+
+// MarshalJSON encodes Extra along with the known fields
+func (t Transfer) MarshalJSON() ([]byte, error) {
+	type transfer Transfer
+	return core.EncodeExtra(transfer(t), t.Extra)
 }
 
 // NewTransfer will initiate a transfer between two user account
@@ -49,14 +100,14 @@ func NewTransfer(ta *TransferArgs) (*Transfer, error) {
 		return nil, eris.Wrap(err, "transfer args")
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "list")
 	}
-	tr := &core.V2ResponseFormat[*Transfer]{}
+	tr := &Transfer{}
 	par := &core.SendParams{
 		RouteName: "custody-transfer-create",
-		Into:      &tr,
+		Into:      tr,
 		Body:      strings.NewReader(string(d)),
 		JWTToken:  tok,
 	}
@@ -66,7 +117,7 @@ func NewTransfer(ta *TransferArgs) (*Transfer, error) {
 		return nil, err
 	}
 
-	return tr.Result, nil
+	return tr, nil
 }
 
 // GetTransfer will return single transfer information based on the supplied transfer ID
@@ -76,25 +127,31 @@ func GetTransfer(transferID string) (*Transfer, error) {
 		return nil, eris.New("empty transfer ID")
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "list")
 	}
 
-	tr := &core.V2ResponseFormat[*Transfer]{}
+	return getTransfer(context.Background(), tok, transferID)
+}
+
+// getTransfer gets a transfer with an already obtained JWT token, the request being canceled with ctx
+func getTransfer(ctx context.Context, tok, transferID string) (*Transfer, error) {
+	tr := &Transfer{}
 	par := &core.SendParams{
+		Context:   ctx,
 		RouteName: "custody-transfer-single",
 		Path:      transferID,
-		Into:      &tr,
+		Into:      tr,
 		JWTToken:  tok,
 	}
 
-	err = core.HTTPSend(par)
+	err := core.HTTPSend(par)
 	if err != nil {
 		return nil, err
 	}
 
-	return tr.Result, nil
+	return tr, nil
 }
 
 // Transfer with return a list of all transfers based on supplied options (which can be nil)
@@ -120,15 +177,15 @@ func ListTransfers(o *ListTransfersOptionArgs) ([]*Transfer, error) {
 		}
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "list")
 	}
 
-	trl := &core.V2ResponseFormat[[]*Transfer]{}
+	trl := make([]*Transfer, 0)
 	par := &core.SendParams{
 		RouteName: "custody-list-transfers",
-		Into:      trl,
+		Into:      &trl,
 		Values:    u,
 		JWTToken:  tok,
 	}
@@ -138,5 +195,90 @@ func ListTransfers(o *ListTransfersOptionArgs) ([]*Transfer, error) {
 		return nil, err
 	}
 
-	return trl.Result, nil
+	return trl, nil
+}
+
+var (
+	transferPollInterval    = 2 * time.Second
+	transferPollMaxInterval = 30 * time.Second
+)
+
+// TransferError is returned by WaitForTransfer when a transfer ends up rejected or failed
+type TransferError struct {
+	Transfer *Transfer
+}
+
+func (e *TransferError) Error() string {
+	return fmt.Sprintf("transfer %s: %s", e.Transfer.Id, strings.ToLower(string(e.Transfer.Status)))
+}
+
+// WaitForTransfer polls the transfer until it reaches a final status, doubling the delay between
+// two calls up to 30 seconds. A *TransferError is returned if the transfer is rejected or failed
+// Temporary errors (network or server side) are retried until ctx is done, the JWT token being obtained
+// once and again only when it expires
+// JWT is required for this request
+func WaitForTransfer(ctx context.Context, transferID string) (*Transfer, error) {
+	if transferID == "" {
+		return nil, eris.New("wait for transfer: empty transfer ID")
+	}
+
+	delay := transferPollInterval
+	var tok string
+	var last *Transfer
+
+	for {
+		var err error
+		if tok == "" {
+			if tok, err = core.AuthenticateFromConfig(); err != nil && !temporary(err) {
+				return last, eris.Wrap(err, "wait for transfer")
+			}
+		}
+
+		if err == nil {
+			var tr *Transfer
+			tr, err = getTransfer(ctx, tok, transferID)
+			switch {
+			case err == nil:
+				last = tr
+				if tr.Status.IsFinal() {
+					if !tr.Status.IsSuccess() {
+						return tr, &TransferError{Transfer: tr}
+					}
+					return tr, nil
+				}
+			case expiredToken(err):
+				tok = ""
+			case ctx.Err() == nil && !temporary(err):
+				return last, eris.Wrap(err, "wait for transfer")
+			}
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return last, eris.Wrap(ctx.Err(), "wait for transfer")
+		case <-t.C:
+		}
+
+		delay *= 2
+		if delay > transferPollMaxInterval {
+			delay = transferPollMaxInterval
+		}
+	}
+}
+
+// temporary tells if a request failing with err may succeed when sent again
+func temporary(err error) bool {
+	var ae *core.APIError
+	if errors.As(err, &ae) {
+		return ae.Temporary()
+	}
+	return true
+}
+
+// expiredToken tells if err is due to an expired JWT token
+func expiredToken(err error) bool {
+	var ae *core.APIError
+	return errors.As(err, &ae) && ae.HTTPStatus == http.StatusUnauthorized
 }
@@ -0,0 +1,180 @@
+package custody
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWaitForTransfer(t *testing.T) {
+	assert := assert.New(t)
+	interval, maxInterval := transferPollInterval, transferPollMaxInterval
+	t.Cleanup(func() {
+		transferPollInterval, transferPollMaxInterval = interval, maxInterval
+	})
+	transferPollInterval = time.Millisecond
+	transferPollMaxInterval = 2 * time.Millisecond
+
+	// statuses returns a mocked API answering with each status in turn on the transfer route
+	statuses := func(st ...string) func(*mocks.HTTPClient) {
+		return func(c *mocks.HTTPClient) {
+			n := 0
+			c.EXPECT().Do(mock.Anything).Call.Return(
+				func(req *http.Request) *http.Response {
+					switch req.URL.Path {
+					case "/v1/auth":
+						return newResponseOK(`{"token":"tok"}`)
+					case "/v1/sub-partner/transfer/T1":
+						s := st[n]
+						if n < len(st)-1 {
+							n++
+						}
+						return newResponseOK(fmt.Sprintf(`{"result":{"id":"T1","status":%q}}`, s))
+					}
+					return nil
+				}, nil)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		init    func(*mocks.HTTPClient)
+		after   func(*Transfer, error)
+	}{
+		{"finished after polling", time.Second, statuses("CREATED", "WAITING", "FINISHED"),
+			func(tr *Transfer, err error) {
+				assert.NoError(err)
+				assert.Equal(TransferFinished, tr.Status)
+			},
+		},
+		{"lower case status", time.Second, statuses("finished"),
+			func(tr *Transfer, err error) {
+				assert.NoError(err)
+			},
+		},
+		{"rejected", time.Second, statuses("WAITING", "REJECTED"),
+			func(tr *Transfer, err error) {
+				var te *TransferError
+				if assert.True(errors.As(err, &te)) {
+					assert.Equal(TransferRejected, te.Transfer.Status)
+				}
+				assert.Equal("transfer T1: rejected", err.Error())
+			},
+		},
+		{"context deadline", 10 * time.Millisecond, statuses("WAITING"),
+			func(tr *Transfer, err error) {
+				assert.ErrorIs(err, context.DeadlineExceeded)
+				assert.Equal(TransferWaiting, tr.Status)
+			},
+		},
+		{"network error retried until done", 10 * time.Millisecond,
+			func(c *mocks.HTTPClient) {
+				c.EXPECT().Do(mock.Anything).Return(nil, errors.New("network error"))
+			},
+			func(tr *Transfer, err error) {
+				assert.Nil(tr)
+				assert.ErrorIs(err, context.DeadlineExceeded)
+			},
+		},
+		{"server error retried with the same token", time.Second,
+			func(c *mocks.HTTPClient) {
+				auths, polls := 0, 0
+				c.EXPECT().Do(mock.Anything).Call.Return(
+					func(req *http.Request) *http.Response {
+						if req.URL.Path == "/v1/auth" {
+							auths++
+							assert.Equal(1, auths, "token is reused between polls")
+							return newResponseOK(`{"token":"tok"}`)
+						}
+						assert.Equal("Bearer tok", req.Header.Get("Authorization"))
+						if polls++; polls < 3 {
+							return newResponse(http.StatusServiceUnavailable, `{"message":"unavailable"}`)
+						}
+						return newResponseOK(`{"result":{"id":"T1","status":"FINISHED"}}`)
+					}, nil)
+			},
+			func(tr *Transfer, err error) {
+				assert.NoError(err)
+				assert.Equal(TransferFinished, tr.Status)
+			},
+		},
+		{"expired token renewed", time.Second,
+			func(c *mocks.HTTPClient) {
+				auths := 0
+				c.EXPECT().Do(mock.Anything).Call.Return(
+					func(req *http.Request) *http.Response {
+						if req.URL.Path == "/v1/auth" {
+							auths++
+							return newResponseOK(fmt.Sprintf(`{"token":"tok%d"}`, auths))
+						}
+						if req.Header.Get("Authorization") == "Bearer tok1" {
+							return newResponse(http.StatusUnauthorized, `{"message":"expired"}`)
+						}
+						return newResponseOK(`{"result":{"id":"T1","status":"FINISHED"}}`)
+					}, nil)
+			},
+			func(tr *Transfer, err error) {
+				assert.NoError(err)
+			},
+		},
+		{"client error not retried", time.Second,
+			func(c *mocks.HTTPClient) {
+				c.EXPECT().Do(mock.Anything).Call.Return(
+					func(req *http.Request) *http.Response {
+						if req.URL.Path == "/v1/auth" {
+							return newResponseOK(`{"token":"tok"}`)
+						}
+						return newResponse(http.StatusNotFound, `{"message":"not found"}`)
+					}, nil).Twice()
+			},
+			func(tr *Transfer, err error) {
+				assert.Nil(tr)
+				assert.Error(err)
+				assert.NotErrorIs(err, context.DeadlineExceeded)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mocks.NewHTTPClient(t)
+			core.UseClient(c)
+			if tt.init != nil {
+				tt.init(c)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			got, err := WaitForTransfer(ctx, "T1")
+			if tt.after != nil {
+				tt.after(got, err)
+			}
+		})
+	}
+}
+
+func TestWaitForTransferContext(t *testing.T) {
+	assert := assert.New(t)
+	c := mocks.NewHTTPClient(t)
+	core.UseClient(c)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "wait")
+	c.EXPECT().Do(mock.Anything).Call.Return(func(req *http.Request) *http.Response {
+		if req.URL.Path == "/v1/auth" {
+			return newResponseOK(`{"token":"tok"}`)
+		}
+		assert.Equal("wait", req.Context().Value(ctxKey{}), "transfer lookups use the wait context")
+		return newResponseOK(`{"result":{"id":"T1","status":"FINISHED"}}`)
+	}, nil)
+
+	_, err := WaitForTransfer(ctx, "T1")
+	assert.NoError(err)
+}
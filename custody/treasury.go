@@ -0,0 +1,154 @@
+package custody
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/rotisserie/eris"
+)
+
+const (
+	defaultTreasuryConcurrency = 4
+	defaultTreasuryPageSize    = 100
+)
+
+// TreasuryOptions are options applying to the treasury report (which can be nil)
+type TreasuryOptions struct {
+	// Concurrency is the maximum number of balance lookups running at the same time (default 4)
+	Concurrency int
+	// PageSize is the number of users fetched per ListUsers call (default 100)
+	PageSize int64
+	// UserIDs restricts the report to these users, skipping the ListUsers calls when set
+	UserIDs []string
+}
+
+// TreasuryUser holds the balances of a single Custody user account
+// Err is set when the balance lookup failed for this user, Balances is nil in that case
+type TreasuryUser struct {
+	User     *User
+	Balances *UserBalances
+	Err      error
+}
+
+// Treasury holds the aggregated balances across all Custody user accounts
+type Treasury struct {
+	Users  []*TreasuryUser
+	Totals map[string]BalanceAmounts
+}
+
+// Failed returns the users for which the balance lookup failed
+func (t *Treasury) Failed() []*TreasuryUser {
+	var fu []*TreasuryUser
+	for _, u := range t.Users {
+		if u.Err != nil {
+			fu = append(fu, u)
+		}
+	}
+	return fu
+}
+
+// Currencies returns the sorted list of currencies found in the report totals
+func (t *Treasury) Currencies() []string {
+	cs := make([]string, 0, len(t.Totals))
+	for c := range t.Totals {
+		cs = append(cs, c)
+	}
+	sort.Strings(cs)
+	return cs
+}
+
+// TreasuryReport fetches the balances of every Custody user account and aggregates them per currency
+// Balance lookups are made concurrently, a failed lookup is reported on the related user and does not abort the report
+// JWT is required for this request (to list users)
+func TreasuryReport(ctx context.Context, o *TreasuryOptions) (*Treasury, error) {
+	if o == nil {
+		o = &TreasuryOptions{}
+	}
+
+	concurrency := o.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultTreasuryConcurrency
+	}
+
+	users, err := treasuryUsers(ctx, o)
+	if err != nil {
+		return nil, eris.Wrap(err, "treasury report")
+	}
+
+	tr := &Treasury{
+		Users:  make([]*TreasuryUser, len(users)),
+		Totals: make(map[string]BalanceAmounts),
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, u := range users {
+		tu := &TreasuryUser{User: u}
+		tr.Users[i] = tu
+
+		select {
+		case <-ctx.Done():
+			tu.Err = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			tu.Balances, tu.Err = getBalance(ctx, tu.User.ID)
+		}()
+	}
+
+	wg.Wait()
+
+	for _, tu := range tr.Users {
+		if tu.Err != nil || tu.Balances == nil {
+			continue
+		}
+		for cur, am := range tu.Balances.Balances {
+			t := tr.Totals[cur]
+			t.Amount += am.Amount
+			t.PendingAmount += am.PendingAmount
+			tr.Totals[cur] = t
+		}
+	}
+
+	return tr, nil
+}
+
+// treasuryUsers returns the users the report applies to, paging ListUsers when no user IDs are supplied
+func treasuryUsers(ctx context.Context, o *TreasuryOptions) ([]*User, error) {
+	if len(o.UserIDs) > 0 {
+		users := make([]*User, 0, len(o.UserIDs))
+		for _, id := range o.UserIDs {
+			users = append(users, &User{ID: id})
+		}
+		return users, nil
+	}
+
+	limit := o.PageSize
+	if limit <= 0 {
+		limit = defaultTreasuryPageSize
+	}
+
+	var users []*User
+	for offset := int64(0); ; offset += limit {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		page, err := ListUsers(&ListCommonOptionsArgs{Limit: limit, Offset: offset})
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, page...)
+		if int64(len(page)) < limit {
+			return users, nil
+		}
+	}
+}
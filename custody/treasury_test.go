@@ -0,0 +1,134 @@
+package custody
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type rc struct {
+	*strings.Reader
+}
+
+func (*rc) Close() error {
+	return nil
+}
+
+func newResponse(code int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Body:       &rc{strings.NewReader(body)},
+	}
+}
+
+func newResponseOK(body string) *http.Response {
+	return newResponse(http.StatusOK, body)
+}
+
+func TestTreasuryReport(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	tests := []struct {
+		name    string
+		o       *TreasuryOptions
+		init    func(*mocks.HTTPClient)
+		wantErr bool
+		after   func(*Treasury)
+	}{
+		{"totals and partial failure", &TreasuryOptions{PageSize: 2, Concurrency: 2},
+			func(c *mocks.HTTPClient) {
+				c.EXPECT().Do(mock.Anything).Call.Return(
+					func(req *http.Request) *http.Response {
+						switch req.URL.Path {
+						case "/v1/auth":
+							return newResponseOK(`{"token":"tok"}`)
+						case "/v1/sub-partner":
+							if req.URL.Query().Get("offset") == "" {
+								return newResponseOK(`{"result":[{"id":"1"},{"id":"2"}]}`)
+							}
+							return newResponseOK(`{"result":[{"id":"3"}]}`)
+						case "/v1/sub-partner/balance/1":
+							return newResponseOK(`{"result":{"subPartnerId":"1","balances":{"usdtbsc":{"amount":1.5,"pendingAmount":1}}}}`)
+						case "/v1/sub-partner/balance/2":
+							return newResponseOK(`{"result":{"subPartnerId":"2","balances":{"usdtbsc":{"amount":2},"usddtrc20":{"amount":3},"btc":{"amount":0.25,"pendingAmount":0.5}}}}`)
+						}
+						return nil
+					},
+					func(req *http.Request) error {
+						if req.URL.Path == "/v1/sub-partner/balance/3" {
+							return errors.New("network error")
+						}
+						return nil
+					})
+			}, false,
+			func(tr *Treasury) {
+				require.Len(tr.Users, 3)
+				assert.Equal("1", tr.Users[0].User.ID)
+				assert.Equal("3", tr.Users[2].User.ID)
+				assert.Equal(BalanceAmounts{Amount: 3.5, PendingAmount: 1}, tr.Totals["usdtbsc"])
+				assert.Equal(BalanceAmounts{Amount: 3}, tr.Totals["usddtrc20"])
+				assert.Equal(BalanceAmounts{Amount: 0.25, PendingAmount: 0.5}, tr.Totals["btc"])
+				assert.Equal([]string{"btc", "usddtrc20", "usdtbsc"}, tr.Currencies())
+				if assert.Len(tr.Failed(), 1) {
+					assert.Equal("3", tr.Failed()[0].User.ID)
+					assert.Equal("custody-account-balance: network error", tr.Failed()[0].Err.Error())
+				}
+			},
+		},
+		{"selected users only", &TreasuryOptions{UserIDs: []string{"7"}},
+			func(c *mocks.HTTPClient) {
+				c.EXPECT().Do(mock.Anything).Run(func(req *http.Request) {
+					assert.Equal("/v1/sub-partner/balance/7", req.URL.Path)
+				}).Return(newResponseOK(`{"result":{"subPartnerId":"7","balances":{"usdtbsc":{"amount":1}}}}`), nil)
+			}, false,
+			func(tr *Treasury) {
+				require.Len(tr.Users, 1)
+				assert.Equal(1.0, tr.Totals["usdtbsc"].Amount)
+			},
+		},
+		{"list users failure", nil,
+			func(c *mocks.HTTPClient) {
+				c.EXPECT().Do(mock.Anything).Return(nil, errors.New("bad credentials"))
+			}, true, nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mocks.NewHTTPClient(t)
+			core.UseClient(c)
+			if tt.init != nil {
+				tt.init(c)
+			}
+			got, err := TreasuryReport(context.Background(), tt.o)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TreasuryReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.after != nil {
+				tt.after(got)
+			}
+		})
+	}
+}
+
+func TestTreasuryReportContext(t *testing.T) {
+	assert := assert.New(t)
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "report")
+
+	c := mocks.NewHTTPClient(t)
+	core.UseClient(c)
+	c.EXPECT().Do(mock.Anything).Run(func(req *http.Request) {
+		assert.Equal("report", req.Context().Value(ctxKey{}), "balance lookups use the report context")
+	}).Return(newResponseOK(`{"result":{"subPartnerId":"7","balances":{}}}`), nil)
+
+	_, err := TreasuryReport(ctx, &TreasuryOptions{UserIDs: []string{"7"}})
+	assert.NoError(err)
+}
@@ -1,14 +1,13 @@
 package custody
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
 	"strings"
-	"time"
 
-	"github.com/CIDgravity/go-nowpayments/config"
 	"github.com/CIDgravity/go-nowpayments/core"
 	"github.com/rotisserie/eris"
 )
@@ -28,16 +27,33 @@ type UserAccountArgs struct {
 type User struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	CreatedAt core.Time `json:"created_at"`
+	UpdatedAt core.Time `json:"updated_at"`
+
+	// Extra holds the fields unknown to the library, encoded along with the others
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps the unknown fields in Extra
+func (u *User) UnmarshalJSON(data []byte) error {
+	type user User
+	extra, err := core.DecodeExtra(data, (*user)(u))
+	if err != nil {
+		return err
+	}
+	u.Extra = extra
+	return nil
 }
 
-// Balances hold multiple balances for Custody user account
-type Balances struct {
-	Usddtrc20 BalanceAmounts `json:"usddtrc20"`
-	Usdtbsc   BalanceAmounts `json:"usdtbsc"`
+// MarshalJSON encodes Extra along with the known fields
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	return core.EncodeExtra(user(u), u.Extra)
 }
 
+// Balances hold the balances of a Custody user account, keyed by currency
+type Balances map[string]BalanceAmounts
+
 // BalanceAmounts single balance for Custody user account
 type BalanceAmounts struct {
 	Amount        float64 `json:"amount"`
@@ -62,16 +78,16 @@ func NewUser(cu *UserAccountArgs) (*User, error) {
 		return nil, eris.Wrap(err, "custody user account args")
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "custody user")
 	}
 
 	// CONSISTENCY PROBLEM ON THEIR SIDE: for some requests response is put under result object
-	us := &core.V2ResponseFormat[*User]{}
+	us := &User{}
 	par := &core.SendParams{
 		RouteName: "custody-create-account",
-		Into:      &us,
+		Into:      us,
 		Body:      strings.NewReader(string(d)),
 		JWTToken:  tok,
 	}
@@ -81,7 +97,7 @@ func NewUser(cu *UserAccountArgs) (*User, error) {
 		return nil, err
 	}
 
-	return us.Result, nil
+	return us, nil
 }
 
 // ListUsers return a list of users based on filters provided in params
@@ -105,15 +121,15 @@ func ListUsers(o *ListCommonOptionsArgs) ([]*User, error) {
 		}
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "list users")
 	}
 
-	usl := &core.V2ResponseFormat[[]*User]{}
+	usl := make([]*User, 0)
 	par := &core.SendParams{
 		RouteName: "custody-list-users",
-		Into:      usl,
+		Into:      &usl,
 		Values:    u,
 		JWTToken:  tok,
 	}
@@ -123,21 +139,27 @@ func ListUsers(o *ListCommonOptionsArgs) ([]*User, error) {
 		return nil, err
 	}
 
-	return usl.Result, nil
+	return usl, nil
 }
 
 // GetBalance get the balances for a specific Custody user account, based on it's unique account ID
 // This endpoint will work only if IP is whitelisted (or white IP restrictions are disabled)
 func GetBalance(userAccountID string) (*UserBalances, error) {
+	return getBalance(context.Background(), userAccountID)
+}
+
+// getBalance gets the balances of a Custody user account, the request being canceled with ctx
+func getBalance(ctx context.Context, userAccountID string) (*UserBalances, error) {
 	if userAccountID == "" {
 		return nil, eris.New("empty user account ID")
 	}
 
-	bl := &core.V2ResponseFormat[*UserBalances]{}
+	bl := &UserBalances{}
 	par := &core.SendParams{
+		Context:   ctx,
 		RouteName: "custody-account-balance",
 		Path:      userAccountID,
-		Into:      &bl,
+		Into:      bl,
 	}
 
 	err := core.HTTPSend(par)
@@ -145,5 +167,5 @@ func GetBalance(userAccountID string) (*UserBalances, error) {
 		return nil, err
 	}
 
-	return bl.Result, nil
+	return bl, nil
 }
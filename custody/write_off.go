@@ -5,7 +5,6 @@ import (
 	"errors"
 	"strings"
 
-	"github.com/CIDgravity/go-nowpayments/config"
 	"github.com/CIDgravity/go-nowpayments/core"
 	"github.com/rotisserie/eris"
 )
@@ -22,15 +21,15 @@ func NewWriteOffToMaster(wo *DepositArgs) (*Transfer, error) {
 		return nil, eris.Wrap(err, "write off args")
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "custody write-off to master")
 	}
 
-	tr := &core.V2ResponseFormat[*Transfer]{}
+	tr := &Transfer{}
 	par := &core.SendParams{
 		RouteName: "custody-write-off-to-master",
-		Into:      &tr,
+		Into:      tr,
 		Body:      strings.NewReader(string(d)),
 		JWTToken:  tok,
 	}
@@ -40,5 +39,5 @@ func NewWriteOffToMaster(wo *DepositArgs) (*Transfer, error) {
 		return nil, err
 	}
 
-	return tr.Result, nil
+	return tr, nil
 }
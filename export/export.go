@@ -0,0 +1,317 @@
+// Package export writes payments to accounting formats: CSV with a stable schema, and double-entry journals
+// readable by ledger and beancount
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/custody"
+	"github.com/CIDgravity/go-nowpayments/payments"
+	"github.com/rotisserie/eris"
+)
+
+const defaultPageSize = 100
+
+// Options are options applying to the payments fetched for an export (which can be nil)
+type Options struct {
+	// From and To are the creation time range of the payments, From being inclusive and To exclusive
+	// A zero value means no bound
+	From time.Time
+	To   time.Time
+	// Custody exports the deposit payments of the Custody user accounts (custody.ListPayments) instead of the
+	// payments (payments.List)
+	Custody bool
+	// SubPartnerID only exports the deposit payments of this Custody user account, Custody being implied
+	SubPartnerID string
+	// PageSize is the number of payments fetched per call (default 100)
+	PageSize int
+}
+
+// Fetch returns all the payments created in the range of the options, oldest first
+// JWT is required for this request
+func Fetch(o *Options) ([]*payments.Payment, error) {
+	if o == nil {
+		o = &Options{}
+	}
+
+	limit := o.PageSize
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+
+	// The API filters on UTC days only, the range is applied on the creation time afterwards
+	from, to := o.From.UTC(), o.To.UTC()
+	if !to.IsZero() {
+		to = to.Add(-time.Nanosecond)
+	}
+
+	var ps []*payments.Payment
+	for page := 0; ; page++ {
+		var pp []*payments.Payment
+		var err error
+		if o.Custody || o.SubPartnerID != "" {
+			pp, err = custody.ListPayments(&custody.ListPaymentsOption{
+				Limit:        int64(limit),
+				Page:         int64(page),
+				SubPartnerID: o.SubPartnerID,
+				DateFrom:     from,
+				DateTo:       to,
+				SortBy:       "created_at",
+				OrderBy:      "asc",
+			})
+		} else {
+			pp, err = payments.List(&payments.ListOption{
+				Limit:    limit,
+				Page:     page,
+				DateFrom: from,
+				DateTo:   to,
+				SortBy:   "created_at",
+				OrderBy:  "asc",
+			})
+		}
+		if err != nil {
+			return nil, eris.Wrap(err, "export")
+		}
+
+		for _, p := range pp {
+			if p == nil || p.CreatedAt.Before(o.From) || (!o.To.IsZero() && !p.CreatedAt.Before(o.To)) {
+				continue
+			}
+			ps = append(ps, p)
+		}
+		if len(pp) < limit {
+			break
+		}
+	}
+	return ps, nil
+}
+
+// CSVHeader is the header of the CSV export, its columns are never reordered nor removed
+var CSVHeader = []string{
+	"payment_id",
+	"created_at",
+	"updated_at",
+	"order_id",
+	"status",
+	"price_amount",
+	"price_currency",
+	"pay_amount",
+	"actually_paid",
+	"pay_currency",
+	"outcome_amount",
+	"outcome_currency",
+	"fee_currency",
+	"deposit_fee",
+	"service_fee",
+	"withdrawal_fee",
+}
+
+// WriteCSV writes the payments as CSV to w, with CSVHeader as first line
+// Timestamps are written in RFC3339 UTC, amounts in decimal notation
+func WriteCSV(w io.Writer, ps []*payments.Payment) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return eris.Wrap(err, "export csv")
+	}
+
+	for _, p := range ps {
+		if p == nil {
+			continue
+		}
+
+		fee := p.Fee
+		if fee == nil {
+			fee = &payments.PaymentFee{}
+		}
+		err := cw.Write([]string{
+			p.ID.String(),
+			formatTime(p.CreatedAt.Time),
+			formatTime(p.UpdatedAt.Time),
+			p.OrderID,
+			p.Status,
+			formatAmount(p.PriceAmount),
+			strings.ToLower(p.PriceCurrency),
+			formatAmount(p.PayAmount.Float64()),
+			formatAmount(p.ActuallyPaid.Float64()),
+			strings.ToLower(p.PayCurrency),
+			formatAmount(p.OutcomeAmount.Float64()),
+			strings.ToLower(p.OutcomeCurrency),
+			strings.ToLower(fee.Currency),
+			formatAmount(fee.DepositFee.Float64()),
+			formatAmount(fee.ServiceFee.Float64()),
+			formatAmount(fee.WithdrawalFee.Float64()),
+		})
+		if err != nil {
+			return eris.Wrap(err, "export csv")
+		}
+	}
+
+	cw.Flush()
+	return eris.Wrap(cw.Error(), "export csv")
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// formatAmount formats an amount with at most 15 significant digits, hiding the floating point sums noise
+func formatAmount(f float64) string {
+	f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// JournalFormat is the syntax of a double-entry journal
+type JournalFormat string
+
+const (
+	Ledger    JournalFormat = "ledger"
+	Beancount JournalFormat = "beancount"
+)
+
+// JournalOptions are options applying to the journal (which can be nil)
+type JournalOptions struct {
+	// Format is the journal syntax (default Ledger)
+	Format JournalFormat
+	// Accounts maps a lower case currency to the account receiving the funds in this currency
+	// Currencies not listed are received on AssetsPrefix:<CURRENCY>
+	Accounts map[string]string
+	// AssetsPrefix is the parent of the accounts receiving the funds (default Assets:NOWPayments)
+	AssetsPrefix string
+	// Income is the account the payments are booked to (default Income:Sales)
+	Income string
+	// Fees is the account the fees are booked to (default Expenses:Fees:NOWPayments)
+	Fees string
+	// Statuses are the statuses of the payments written to the journal (default finished)
+	Statuses []string
+}
+
+func (o *JournalOptions) withDefaults() *JournalOptions {
+	r := JournalOptions{}
+	if o != nil {
+		r = *o
+	}
+	if r.Format == "" {
+		r.Format = Ledger
+	}
+	if r.AssetsPrefix == "" {
+		r.AssetsPrefix = "Assets:NOWPayments"
+	}
+	if r.Income == "" {
+		r.Income = "Income:Sales"
+	}
+	if r.Fees == "" {
+		r.Fees = "Expenses:Fees:NOWPayments"
+	}
+	if len(r.Statuses) == 0 {
+		r.Statuses = []string{"finished"}
+	}
+	return &r
+}
+
+// account returns the account receiving the funds in a currency
+func (o *JournalOptions) account(currency string) string {
+	if a, ok := o.Accounts[strings.ToLower(currency)]; ok {
+		return a
+	}
+	return o.AssetsPrefix + ":" + strings.ToUpper(currency)
+}
+
+func (o *JournalOptions) keep(status string) bool {
+	for _, s := range o.Statuses {
+		if strings.EqualFold(s, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJournal writes a double-entry transaction per payment to w
+// The funds received, the outcome amount or the actually paid one without outcome, are debited to the account of
+// their currency, and the fees to the fees account when charged in the same currency. The income account is
+// credited of the price amount, converted at the payment rate. Other fees are kept as metadata only
+func WriteJournal(w io.Writer, ps []*payments.Payment, o *JournalOptions) error {
+	o = o.withDefaults()
+	if o.Format != Ledger && o.Format != Beancount {
+		return eris.Errorf("export journal: unknown format %q", o.Format)
+	}
+
+	for _, p := range ps {
+		if p == nil || !o.keep(p.Status) {
+			continue
+		}
+		if _, err := io.WriteString(w, transaction(p, o)); err != nil {
+			return eris.Wrap(err, "export journal")
+		}
+	}
+	return nil
+}
+
+// transaction returns the journal transaction of a payment
+func transaction(p *payments.Payment, o *JournalOptions) string {
+	amount, currency := p.OutcomeAmount.Float64(), p.OutcomeCurrency
+	if currency == "" || amount == 0 {
+		amount, currency = p.ActuallyPaid.Float64(), p.PayCurrency
+	}
+	currency = strings.ToUpper(currency)
+
+	fee := 0.0
+	if p.Fee != nil && strings.EqualFold(p.Fee.Currency, currency) {
+		fee = p.Fee.Total()
+	}
+
+	narration := "payment " + p.ID.String()
+	if p.OrderID != "" {
+		narration += " order " + p.OrderID
+	}
+
+	meta := [][2]string{{"payment_id", p.ID.String()}, {"status", p.Status}}
+	if p.OrderID != "" {
+		meta = append(meta, [2]string{"order_id", p.OrderID})
+	}
+	if p.Fee != nil && fee == 0 && p.Fee.Total() != 0 {
+		meta = append(meta, [2]string{"fee", formatAmount(p.Fee.Total()) + " " + strings.ToUpper(p.Fee.Currency)})
+	}
+
+	var b strings.Builder
+	date := p.CreatedAt.UTC().Format("2006-01-02")
+	switch o.Format {
+	case Beancount:
+		fmt.Fprintf(&b, "%s * %q %q\n", date, "NOWPayments", narration)
+		for _, m := range meta {
+			fmt.Fprintf(&b, "  %s: %q\n", m[0], m[1])
+		}
+	default:
+		fmt.Fprintf(&b, "%s * NOWPayments %s\n", date, narration)
+		for _, m := range meta {
+			fmt.Fprintf(&b, "    ; %s: %s\n", m[0], m[1])
+		}
+	}
+
+	posting(&b, o.account(currency), formatAmount(amount)+" "+currency)
+	if fee != 0 {
+		posting(&b, o.Fees, formatAmount(fee)+" "+currency)
+	}
+
+	received := formatAmount(amount+fee) + " " + currency
+	price := strings.ToUpper(p.PriceCurrency)
+	if price == "" || price == currency || p.PriceAmount == 0 {
+		posting(&b, o.Income, "-"+received)
+	} else {
+		posting(&b, o.Income, "-"+formatAmount(p.PriceAmount)+" "+price+" @@ "+received)
+	}
+
+	b.WriteByte('\n')
+	return b.String()
+}
+
+func posting(b *strings.Builder, account, amount string) {
+	fmt.Fprintf(b, "    %-40s %s\n", account, amount)
+}
@@ -0,0 +1,161 @@
+package export
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/config"
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/mocks"
+	"github.com/CIDgravity/go-nowpayments/payments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type rc struct {
+	*strings.Reader
+}
+
+func (*rc) Close() error {
+	return nil
+}
+
+func newResponseOK(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       &rc{strings.NewReader(body)},
+	}
+}
+
+func at(day, hour int) core.Time {
+	return core.Time{Time: time.Date(2024, 1, day, hour, 0, 0, 0, time.UTC)}
+}
+
+func testPayments() []*payments.Payment {
+	p := &payments.Payment{
+		ID:              "5077125051",
+		Status:          "finished",
+		ActuallyPaid:    100.2,
+		PayAmount:       100.2,
+		PayCurrency:     "usdttrc20",
+		OutcomeAmount:   99.5,
+		OutcomeCurrency: "usdttrc20",
+		Fee:             &payments.PaymentFee{Currency: "usdttrc20", DepositFee: 0.2, ServiceFee: 0.3},
+		CreatedAt:       at(2, 10),
+		UpdatedAt:       at(2, 11),
+	}
+	p.PriceAmount = 100
+	p.PriceCurrency = "usd"
+	p.OrderID = "order-1"
+
+	w := &payments.Payment{
+		ID:          "5077125052",
+		Status:      "waiting",
+		PayAmount:   0.001,
+		PayCurrency: "btc",
+		CreatedAt:   at(3, 10),
+	}
+	w.PriceAmount = 50
+	w.PriceCurrency = "usd"
+	return []*payments.Payment{p, w}
+}
+
+func TestWriteCSV(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var b bytes.Buffer
+	require.NoError(WriteCSV(&b, testPayments()))
+	assert.Equal(`payment_id,created_at,updated_at,order_id,status,price_amount,price_currency,pay_amount,actually_paid,pay_currency,outcome_amount,outcome_currency,fee_currency,deposit_fee,service_fee,withdrawal_fee
+5077125051,2024-01-02T10:00:00Z,2024-01-02T11:00:00Z,order-1,finished,100,usd,100.2,100.2,usdttrc20,99.5,usdttrc20,usdttrc20,0.2,0.3,0
+5077125052,2024-01-03T10:00:00Z,,,waiting,50,usd,0.001,0,btc,0,,,0,0,0
+`, b.String())
+}
+
+func TestWriteJournal(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	t.Run("ledger", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(WriteJournal(&b, testPayments(), nil))
+		assert.Equal(`2024-01-02 * NOWPayments payment 5077125051 order order-1
+    ; payment_id: 5077125051
+    ; status: finished
+    ; order_id: order-1
+    Assets:NOWPayments:USDTTRC20             99.5 USDTTRC20
+    Expenses:Fees:NOWPayments                0.5 USDTTRC20
+    Income:Sales                             -100 USD @@ 100 USDTTRC20
+
+`, b.String())
+	})
+
+	t.Run("beancount with accounts", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(WriteJournal(&b, testPayments(), &JournalOptions{
+			Format:   Beancount,
+			Accounts: map[string]string{"usdttrc20": "Assets:Crypto:USDT"},
+			Income:   "Income:Shop",
+			Statuses: []string{"finished", "waiting"},
+		}))
+		assert.Equal(`2024-01-02 * "NOWPayments" "payment 5077125051 order order-1"
+  payment_id: "5077125051"
+  status: "finished"
+  order_id: "order-1"
+    Assets:Crypto:USDT                       99.5 USDTTRC20
+    Expenses:Fees:NOWPayments                0.5 USDTTRC20
+    Income:Shop                              -100 USD @@ 100 USDTTRC20
+
+2024-01-03 * "NOWPayments" "payment 5077125052"
+  payment_id: "5077125052"
+  status: "waiting"
+    Assets:NOWPayments:BTC                   0 BTC
+    Income:Shop                              -50 USD @@ 0 BTC
+
+`, b.String())
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		assert.Error(WriteJournal(&bytes.Buffer{}, testPayments(), &JournalOptions{Format: "gnucash"}))
+	})
+}
+
+func TestFetch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	require.NoError(config.Load(&config.Credentials{Login: "l", Password: "p", APIKey: "key", Server: "http://some.tld"}))
+	c := mocks.NewHTTPClient(t)
+	core.UseClient(c)
+
+	c.EXPECT().Do(mock.Anything).Call.Return(
+		func(req *http.Request) *http.Response {
+			switch req.URL.Path {
+			case "/v1/auth":
+				return newResponseOK(`{"token":"tok"}`)
+			case "/v1/payment/":
+				q := req.URL.Query()
+				assert.Equal("2024-01-02", q.Get("dateFrom"))
+				assert.Equal("2024-01-03", q.Get("dateTo"))
+				if q.Get("page") == "0" {
+					return newResponseOK(`{"data":[
+						{"payment_id":1,"created_at":"2024-01-02T09:00:00.000Z"},
+						{"payment_id":2,"created_at":"2024-01-02T10:00:00.000Z"}]}`)
+				}
+				return newResponseOK(`{"data":[{"payment_id":3,"created_at":"2024-01-03T10:00:00.000Z"}]}`)
+			default:
+				t.Fatalf("unexpected route call %q", req.URL.Path)
+			}
+			return nil
+		}, nil)
+
+	ps, err := Fetch(&Options{From: at(2, 10).Time, To: at(3, 10).Time, PageSize: 2})
+	require.NoError(err)
+	if assert.Len(ps, 1) {
+		assert.Equal(core.ID("2"), ps[0].ID)
+	}
+}
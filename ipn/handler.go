@@ -0,0 +1,175 @@
+package ipn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/CIDgravity/go-nowpayments/config"
+)
+
+// SignatureHeader is the header holding the signature of the IPN payloads
+const SignatureHeader = "x-nowpayments-sig"
+
+// MaxPayloadSize is the maximum size of the IPN payloads read by Handler, larger ones being rejected
+var MaxPayloadSize int64 = 1 << 20
+
+// Callback handles a verified IPN notification, ctx being the context of the IPN request
+// Returning an error answers with a 500 status code, so that NOWPayments sends the notification again
+type Callback func(ctx context.Context, n *IPNPaymentStatus) error
+
+// Outcome is a step of the handling of an IPN notification, reported to Handler.Observe
+type Outcome string
+
+const (
+	// Received is reported for each request, before any other outcome
+	Received Outcome = "received"
+	// Verified is reported when the signature and payload are valid
+	Verified Outcome = "verified"
+	// Rejected is reported when the payload can't be read or its signature is wrong
+	Rejected Outcome = "rejected"
+	// Duplicate is reported when a notification already handled is received again, the callback not being called
+	Duplicate Outcome = "duplicate"
+	// Failed is reported when the callback returns an error
+	Failed Outcome = "failed"
+)
+
+// Handler is the HTTP handler of the IPN callback endpoint, verifying the notifications before passing them to
+// its Callback
+type Handler struct {
+	// Secret is the IPN secret key, config.IPNSecretKey() when empty
+	Secret   string
+	Callback Callback
+	// Dedup is the number of notifications handled remembered to skip the ones sent again, 0 disabling it
+	// A notification is a duplicate when its payment ID, status and paid amount were already handled, or are being
+	// handled by a concurrent request
+	Dedup int
+	// Observe is called with the outcomes of each notification when not nil, e.g. to count them
+	Observe func(Outcome)
+
+	mu   sync.Mutex
+	seen map[string]bool
+	keys []string
+}
+
+// NewHandler returns a handler passing the notifications signed with the configured IPN secret key to cb
+func NewHandler(cb Callback) *Handler {
+	return &Handler{Callback: cb}
+}
+
+// ServeHTTP verifies the signature of the notification and passes it to the callback
+// It answers with a 400 status code when the payload can't be read, 413 when it is larger than MaxPayloadSize,
+// 401 when its signature is wrong, and 500 when the configured IPN secret key can't be resolved
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.observe(Received)
+
+	payload, err := io.ReadAll(io.LimitReader(r.Body, MaxPayloadSize+1))
+	if err != nil {
+		h.observe(Rejected)
+		http.Error(w, "can't read payload", http.StatusBadRequest)
+		return
+	}
+	if int64(len(payload)) > MaxPayloadSize {
+		h.observe(Rejected)
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	secret := h.Secret
+	if secret == "" {
+		if secret, err = config.ResolveIPNSecretKey(); err != nil {
+			h.observe(Failed)
+			http.Error(w, "IPN secret key unavailable", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := VerifySignature(secret, payload, r.Header.Get(SignatureHeader)); err != nil {
+		h.observe(Rejected)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	n := &IPNPaymentStatus{}
+	if err := json.Unmarshal(payload, n); err != nil {
+		h.observe(Rejected)
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	h.observe(Verified)
+
+	key := fmt.Sprintf("%s/%s/%v", n.PaymentID, n.PaymentStatus, n.ActuallyPaid)
+	if !h.reserve(key) {
+		h.observe(Duplicate)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if h.Callback != nil {
+		if err := h.Callback(r.Context(), n); err != nil {
+			h.release(key)
+			h.observe(Failed)
+			http.Error(w, "notification not handled", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) observe(o Outcome) {
+	if h.Observe != nil {
+		h.Observe(o)
+	}
+}
+
+// reserve records the notification of key as handled before calling back, forgetting the oldest one beyond Dedup
+// It returns false when the notification was already handled or is being handled
+func (h *Handler) reserve(key string) bool {
+	if h.Dedup <= 0 {
+		return true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.seen == nil {
+		h.seen = map[string]bool{}
+	}
+	if h.seen[key] {
+		return false
+	}
+	h.seen[key] = true
+	h.keys = append(h.keys, key)
+	for len(h.keys) > h.Dedup {
+		delete(h.seen, h.keys[0])
+		h.keys = h.keys[1:]
+	}
+	return true
+}
+
+// release forgets the notification of key when the callback failed, so that it is handled when sent again
+func (h *Handler) release(key string) {
+	if h.Dedup <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.seen[key] {
+		return
+	}
+	delete(h.seen, key)
+	for i, k := range h.keys {
+		if k == key {
+			h.keys = append(h.keys[:i], h.keys[i+1:]...)
+			break
+		}
+	}
+}
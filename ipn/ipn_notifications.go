@@ -1,66 +1,85 @@
 package ipn
 
 import (
-	"crypto/hmac"
-	"crypto/sha512"
 	"encoding/json"
-	"fmt"
 
 	"github.com/CIDgravity/go-nowpayments/config"
+	"github.com/CIDgravity/go-nowpayments/core"
 	"github.com/rotisserie/eris"
 )
 
 type IPNPaymentFees struct {
-	Currency      string  `json:"currency"`
-	DepositFee    float64 `json:"depositFee"`
-	ServiceFee    float64 `json:"serviceFee"`
-	WithdrawalFee float64 `json:"withdrawalFee"`
+	Currency      string     `json:"currency"`
+	DepositFee    core.Float `json:"depositFee"`
+	ServiceFee    core.Float `json:"serviceFee"`
+	WithdrawalFee core.Float `json:"withdrawalFee"`
 }
 
 // PaymentStatus holds payment status related information
 // Docs found on https://documenter.getpostman.com/view/7907941/2s93JusNJt#62a6d281-478d-4927-8cd0-f96d677b8de6
 // Docs said IPN response is similar to PaymentStatus, but it's not the case
-// And because this struct is used to compare a signature (callback), must be exactly the same
-// Signature will be verified using VerifyRequestSignature method
+// IDs and amounts are accepted as JSON strings or numbers. A decoded notification keeps its original payload,
+// which VerifyRequestSignature checks, otherwise the struct is encoded and must be exactly the same as the one sent
 type IPNPaymentStatus struct {
-	ActuallyPaid       float64        `json:"actually_paid"`
-	ActuallyPaidAtFiat float64        `json:"actually_paid_at_fiat"`
+	ActuallyPaid       core.Float     `json:"actually_paid"`
+	ActuallyPaidAtFiat core.Float     `json:"actually_paid_at_fiat"`
 	Fee                IPNPaymentFees `json:"fee"`
-	InvoiceID          int64          `json:"invoice_id"`
+	InvoiceID          core.ID        `json:"invoice_id"`
 	OrderDescription   string         `json:"order_description"`
 	OrderID            string         `json:"order_id"`
-	OutcomeAmount      float64        `json:"outcome_amount"`
+	OutcomeAmount      core.Float     `json:"outcome_amount"`
 	OutcomeCurrency    string         `json:"outcome_currency"`
-	ParentPaymentId    *int64         `json:"parent_payment_id"`
+	ParentPaymentId    *core.ID       `json:"parent_payment_id"`
 	PayAddress         string         `json:"pay_address"`
-	PayAmount          float64        `json:"pay_amount"`
+	PayAmount          core.Float     `json:"pay_amount"`
 	PayCurrency        string         `json:"pay_currency"`
-	PayinExtraID       *int64         `json:"payin_extra_id"`
-	PaymentExtraIds    []int64        `json:"payment_extra_ids"`
-	PaymentID          int64          `json:"payment_id"`
+	PayinExtraID       *core.ID       `json:"payin_extra_id"`
+	PaymentExtraIds    []core.ID      `json:"payment_extra_ids"`
+	PaymentID          core.ID        `json:"payment_id"`
 	PaymentStatus      string         `json:"payment_status"`
-	PriceAmount        float64        `json:"price_amount"`
+	PriceAmount        core.Float     `json:"price_amount"`
 	PriceCurrency      string         `json:"price_currency"`
-	PurchaseID         string         `json:"purchase_id"`
-}
+	PurchaseID         core.ID        `json:"purchase_id"`
 
-func VerifyRequestSignature(expectedSignature string, ipnNotificationBody IPNPaymentStatus) error {
-	responseBodyAsBytes, err := json.Marshal(ipnNotificationBody)
+	// Extra holds the fields unknown to the library, encoded along with the others
+	Extra map[string]json.RawMessage `json:"-"`
+
+	// payload is the notification as received, used to verify its signature
+	payload []byte
+}
 
+// UnmarshalJSON keeps the unknown fields in Extra
+func (n *IPNPaymentStatus) UnmarshalJSON(data []byte) error {
+	type iPNPaymentStatus IPNPaymentStatus
+	extra, err := core.DecodeExtra(data, (*iPNPaymentStatus)(n))
 	if err != nil {
 		return err
 	}
+	n.Extra = extra
+	n.payload = append([]byte(nil), data...)
+	return nil
+}
 
-	// Create hmac sha512 using IPNSecretKey from config and response body
-	// the sort of keys in struct can be important, sometimes the signatures will differ if order differ
-	digest := hmac.New(sha512.New, []byte(config.IPNSecretKey()))
-	digest.Write(responseBodyAsBytes)
-	generatedSignature := digest.Sum(nil)
+// MarshalJSON encodes Extra along with the known fields
+func (n IPNPaymentStatus) MarshalJSON() ([]byte, error) {
+	type iPNPaymentStatus IPNPaymentStatus
+	return core.EncodeExtra(iPNPaymentStatus(n), n.Extra)
+}
 
-	// Compare generated signature to expectedSignature
-	if fmt.Sprintf("%x", generatedSignature) == expectedSignature {
-		return nil
-	} else {
-		return eris.Wrap(fmt.Errorf("HMAC signature does not match"), "IPN signature verification")
+func VerifyRequestSignature(expectedSignature string, ipnNotificationBody IPNPaymentStatus) error {
+	var err error
+	responseBodyAsBytes := ipnNotificationBody.payload
+	if responseBodyAsBytes == nil {
+		responseBodyAsBytes, err = json.Marshal(ipnNotificationBody)
+		if err != nil {
+			return err
+		}
 	}
+
+	secret, err := config.ResolveIPNSecretKey()
+	if err != nil {
+		return eris.Wrap(err, "IPN signature verification")
+	}
+
+	return VerifySignature(secret, responseBodyAsBytes, expectedSignature)
 }
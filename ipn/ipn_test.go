@@ -0,0 +1,247 @@
+package ipn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/config"
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	require.NoError(config.Load(&config.Credentials{
+		APIKey: "key", IPNSecretKey: "secret", Login: "l", Password: "p", Server: "http://x",
+	}))
+
+	n := IPNPaymentStatus{PaymentID: "5077125051", PaymentStatus: "finished", PayAmount: 0.00033, OrderID: "order-1"}
+	body, err := json.Marshal(n)
+	require.NoError(err)
+	assert.Contains(string(body), `"payment_id":5077125051`, "IDs encoded as numbers like NOWPayments does")
+	assert.Contains(string(body), `"invoice_id":null`)
+
+	sig, err := Sign("secret", body)
+	require.NoError(err)
+	assert.NoError(VerifyRequestSignature(sig, n))
+	assert.NoError(VerifySignature("secret", body, sig))
+	assert.Error(VerifySignature("other", body, sig))
+
+	a, err := Sign("secret", []byte(`{"b":1.10,"a":{"d":"<x>","c":null}}`))
+	require.NoError(err)
+	b, err := Sign("secret", []byte(`{ "a": {"c": null, "d": "<x>"}, "b": 1.10 }`))
+	require.NoError(err)
+	assert.Equal(a, b, "keys order and spacing don't matter")
+
+	_, err = Sign("secret", []byte(`[1]`))
+	assert.Error(err)
+
+	// Fields added by NOWPayments are kept, so that the signature is still valid
+	n.OrderID = "a&b"
+	payload, err := json.Marshal(n)
+	require.NoError(err)
+	payload = append(payload[:len(payload)-1], `,"new_field":{"b":1,"a":2}}`...)
+	sig, err = Sign("secret", payload)
+	require.NoError(err)
+	var withExtra IPNPaymentStatus
+	require.NoError(json.Unmarshal(payload, &withExtra))
+	assert.Equal(json.RawMessage(`{"b":1,"a":2}`), withExtra.Extra["new_field"])
+	assert.NoError(VerifyRequestSignature(sig, withExtra))
+
+	// IDs and amounts sent as numbers or strings are decoded, the signature is checked on the payload received
+	payload = []byte(`{"payment_id":5077125051,"invoice_id":"42","purchase_id":6084744717,"pay_amount":"0.00033","actually_paid":1e-4}`)
+	sig, err = Sign("secret", payload)
+	require.NoError(err)
+	var mixed IPNPaymentStatus
+	require.NoError(json.Unmarshal(payload, &mixed))
+	assert.Equal(core.ID("5077125051"), mixed.PaymentID)
+	assert.Equal(core.ID("42"), mixed.InvoiceID)
+	assert.Equal(core.ID("6084744717"), mixed.PurchaseID)
+	assert.Equal(core.Float(0.00033), mixed.PayAmount)
+	assert.Equal(core.Float(0.0001), mixed.ActuallyPaid)
+	assert.NoError(VerifyRequestSignature(sig, mixed))
+}
+
+func TestSender(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	payload := []byte(`{"payment_id":1,"payment_status":"finished"}`)
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(payload, body)
+		assert.NoError(VerifySignature("secret", body, r.Header.Get("x-nowpayments-sig")))
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewSender("secret")
+	s.Backoff = time.Millisecond
+
+	code, err := s.Send(context.Background(), srv.URL, payload)
+	require.NoError(err)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(3, calls)
+
+	calls = 0
+	s.Retries = 1
+	code, err = s.Send(context.Background(), srv.URL, payload)
+	assert.Error(err)
+	assert.Equal(http.StatusBadGateway, code)
+	assert.Equal(2, calls)
+}
+
+func TestHandler(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	type ctxKey struct{}
+	var got *IPNPaymentStatus
+	fail := false
+	h := &Handler{Secret: "secret", Callback: func(ctx context.Context, n *IPNPaymentStatus) error {
+		assert.Equal("value", ctx.Value(ctxKey{}), "request context passed")
+		got = n
+		if fail {
+			return errors.New("callback failed")
+		}
+		return nil
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, "value")))
+	}))
+	defer srv.Close()
+
+	payload := []byte(`{"payment_id":1,"payment_status":"finished","order_id":"a&b","purchase_id":2}`)
+	sig, err := Sign("secret", payload)
+	require.NoError(err)
+
+	post := func(payload []byte, sig string) int {
+		req, err := http.NewRequest(http.MethodPost, srv.URL, bytes.NewReader(payload))
+		require.NoError(err)
+		req.Header.Set(SignatureHeader, sig)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	assert.Equal(http.StatusOK, post(payload, sig))
+	require.NotNil(got)
+	assert.Equal(core.ID("1"), got.PaymentID)
+	assert.Equal(core.ID("2"), got.PurchaseID)
+	assert.Equal("a&b", got.OrderID)
+
+	got = nil
+	assert.Equal(http.StatusUnauthorized, post(payload, "bad"))
+	assert.Nil(got)
+
+	fail = true
+	assert.Equal(http.StatusInternalServerError, post(payload, sig))
+
+	large := append(bytes.Repeat([]byte(" "), int(MaxPayloadSize)), payload...)
+	sig, err = Sign("secret", large)
+	require.NoError(err)
+	got = nil
+	assert.Equal(http.StatusRequestEntityTooLarge, post(large, sig))
+	assert.Nil(got)
+
+	res, err := http.Get(srv.URL)
+	require.NoError(err)
+	res.Body.Close()
+	assert.Equal(http.StatusMethodNotAllowed, res.StatusCode)
+}
+
+func TestHandlerDedup(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	calls := 0
+	var outcomes []Outcome
+	h := &Handler{Secret: "secret", Dedup: 1,
+		Callback: func(ctx context.Context, n *IPNPaymentStatus) error { calls++; return nil },
+		Observe:  func(o Outcome) { outcomes = append(outcomes, o) },
+	}
+
+	post := func(payload string) int {
+		sig, err := Sign("secret", []byte(payload))
+		require.NoError(err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(payload)))
+		req.Header.Set(SignatureHeader, sig)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	waiting := `{"payment_id":1,"payment_status":"waiting"}`
+	finished := `{"payment_id":1,"payment_status":"finished"}`
+	assert.Equal(http.StatusOK, post(waiting))
+	assert.Equal(http.StatusOK, post(waiting))
+	assert.Equal(1, calls, "duplicate skipped")
+	assert.Equal([]Outcome{Received, Verified, Received, Verified, Duplicate}, outcomes)
+
+	assert.Equal(http.StatusOK, post(finished))
+	assert.Equal(http.StatusOK, post(waiting))
+	assert.Equal(3, calls, "oldest notification forgotten")
+}
+
+func TestHandlerDedupConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	payload := []byte(`{"payment_id":1,"payment_status":"finished"}`)
+	sig, err := Sign("secret", payload)
+	require.NoError(err)
+
+	var calls int32
+	fail := true
+	started, done := make(chan struct{}), make(chan struct{})
+	h := &Handler{Secret: "secret", Dedup: 10,
+		Callback: func(ctx context.Context, n *IPNPaymentStatus) error {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+				<-done
+			}
+			if fail {
+				return errors.New("callback failed")
+			}
+			return nil
+		},
+	}
+
+	post := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+		req.Header.Set(SignatureHeader, sig)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	first := make(chan int)
+	go func() { first <- post() }()
+	<-started
+	assert.Equal(http.StatusOK, post(), "duplicate of a notification being handled")
+	close(done)
+	assert.Equal(http.StatusInternalServerError, <-first)
+	assert.Equal(int32(1), atomic.LoadInt32(&calls), "callback not called concurrently")
+
+	// The failed notification is handled when sent again
+	fail = false
+	assert.Equal(http.StatusOK, post())
+	assert.Equal(http.StatusOK, post())
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,90 @@
+package ipn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rotisserie/eris"
+)
+
+// Sender POSTs signed IPN payloads to a webhook endpoint, the way NOWPayments does
+type Sender struct {
+	// Secret is the IPN secret key used to sign payloads
+	Secret string
+	// Client is the HTTP client used to send the requests, http.DefaultClient when nil
+	Client *http.Client
+	// Retries is the number of additional attempts when the endpoint can't be reached
+	// or answers with a 5xx or 429 status code
+	Retries int
+	// Backoff is the delay before the first retry, doubled after each attempt
+	Backoff time.Duration
+}
+
+// NewSender returns a sender signing payloads with secret, retrying 3 times
+func NewSender(secret string) *Sender {
+	return &Sender{
+		Secret:  secret,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+		Retries: 3,
+		Backoff: time.Second,
+	}
+}
+
+// Send POSTs the payload to url with its x-nowpayments-sig header
+// The status code of the last attempt is returned, along with an error if the endpoint didn't answer with a 2xx
+func (s *Sender) Send(ctx context.Context, url string, payload []byte) (int, error) {
+	sig, err := Sign(s.Secret, payload)
+	if err != nil {
+		return 0, err
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	backoff := s.Backoff
+	var code int
+	for attempt := 0; ; attempt++ {
+		code, err = s.send(ctx, client, url, payload, sig)
+		if err == nil || attempt >= s.Retries || !retryable(code) {
+			return code, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return code, eris.Wrap(ctx.Err(), "IPN send")
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (s *Sender) send(ctx context.Context, client *http.Client, url string, payload []byte, sig string) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, eris.Wrap(err, "IPN send")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, sig)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, eris.Wrap(err, "IPN send")
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, eris.Wrap(fmt.Errorf("unexpected status %s", res.Status), "IPN send")
+	}
+	return res.StatusCode, nil
+}
+
+// retryable tells if an attempt ending with code is worth retrying, 0 being a network error
+func retryable(code int) bool {
+	return code == 0 || code == http.StatusTooManyRequests || code >= 500
+}
@@ -0,0 +1,55 @@
+package ipn
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/rotisserie/eris"
+)
+
+// Sign returns the x-nowpayments-sig value NOWPayments would send along with the payload
+// The payload is a JSON object, its keys are sorted recursively before computing the HMAC-SHA512 with the IPN secret
+func Sign(secret string, payload []byte) (string, error) {
+	c, err := canonical(payload)
+	if err != nil {
+		return "", eris.Wrap(err, "IPN signature")
+	}
+
+	digest := hmac.New(sha512.New, []byte(secret))
+	digest.Write(c)
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// VerifySignature checks the signature of a raw payload, as received by a webhook endpoint
+func VerifySignature(secret string, payload []byte, signature string) error {
+	expected, err := Sign(secret, payload)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return eris.New("IPN signature verification: HMAC signature does not match")
+	}
+	return nil
+}
+
+// canonical returns the payload with sorted keys, numbers being kept as is
+func canonical(payload []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(payload))
+	d.UseNumber()
+	var v map[string]interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	// Like JSON.stringify, do not escape HTML characters
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,261 @@
+// Package metrics measures the API calls, JWT authentications and IPN notifications, without dependency
+//
+// The metrics are exposed in the Prometheus text format, and can be gathered to be bridged to any other
+// metrics library, e.g. by a prometheus.Collector
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/ipn"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the request duration histogram buckets
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metric types
+const (
+	Counter   = "counter"
+	Histogram = "histogram"
+)
+
+// Label is a label of a sample
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a value of a metric family, Name being suffixed with _bucket, _sum or _count for histograms
+type Sample struct {
+	Name   string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a metric with its samples
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+type callKey struct {
+	route, class string
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Metrics counts the API calls, JWT authentications and IPN notifications
+type Metrics struct {
+	buckets []float64
+
+	mu          sync.Mutex
+	calls       map[callKey]*histogram
+	retries     map[string]float64
+	rateLimited map[string]float64
+	auths       map[string]float64
+	ipn         map[ipn.Outcome]float64
+}
+
+// New returns empty metrics, the request durations being measured with the buckets, DefaultBuckets when none
+func New(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+
+	return &Metrics{
+		buckets:     b,
+		calls:       map[callKey]*histogram{},
+		retries:     map[string]float64{},
+		rateLimited: map[string]float64{},
+		auths:       map[string]float64{},
+		ipn:         map[ipn.Outcome]float64{},
+	}
+}
+
+// statusClass returns the class of a status code: 2xx, 4xx... or error when no response was received
+func statusClass(code int) string {
+	if code == 0 {
+		return "error"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// Middleware returns a core middleware measuring the API calls, to register with core.UseMiddleware
+func (m *Metrics) Middleware() core.Middleware {
+	return func(next core.RoundTrip) core.RoundTrip {
+		return func(c *core.Invocation) error {
+			start := time.Now()
+			err := next(c)
+			m.observeCall(c, err, time.Since(start))
+			return err
+		}
+	}
+}
+
+func (m *Metrics) observeCall(c *core.Invocation, err error, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := callKey{c.RouteName, statusClass(c.StatusCode)}
+	h := m.calls[k]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.calls[k] = h
+	}
+	s := d.Seconds()
+	for i, b := range m.buckets {
+		if s <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += s
+
+	if c.Attempt > 1 {
+		m.retries[c.RouteName] += float64(c.Attempt - 1)
+	}
+	if c.RateLimited > 0 {
+		m.rateLimited[c.RouteName] += float64(c.RateLimited)
+	}
+	if c.RouteName == "auth" {
+		if err == nil {
+			m.auths["success"]++
+		} else {
+			m.auths["failure"]++
+		}
+	}
+}
+
+// ObserveIPN counts an IPN notification outcome, to set as ipn.Handler Observe
+func (m *Metrics) ObserveIPN(o ipn.Outcome) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ipn[o]++
+}
+
+// counter returns the family of a counter, its samples being sorted by label value
+func counter(name, help, label string, values map[string]float64) Family {
+	f := Family{Name: name, Help: help, Type: Counter}
+	for _, v := range sortedKeys(values) {
+		f.Samples = append(f.Samples, Sample{Name: name, Labels: []Label{{label, v}}, Value: values[v]})
+	}
+	return f
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Gather returns a snapshot of the metric families
+func (m *Metrics) Gather() []Family {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]callKey, 0, len(m.calls))
+	for k := range m.calls {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].class < keys[j].class
+	})
+
+	requests := Family{Name: "nowpayments_requests_total", Help: "API calls by route and status class", Type: Counter}
+	durations := Family{Name: "nowpayments_request_duration_seconds", Help: "API calls duration by route and status class",
+		Type: Histogram}
+	for _, k := range keys {
+		h := m.calls[k]
+		labels := []Label{{"route", k.route}, {"status_class", k.class}}
+		requests.Samples = append(requests.Samples, Sample{Name: requests.Name, Labels: labels, Value: float64(h.count)})
+
+		for i, b := range m.buckets {
+			durations.Samples = append(durations.Samples, Sample{Name: durations.Name + "_bucket",
+				Labels: append(labels[:2:2], Label{"le", formatFloat(b)}), Value: float64(h.counts[i])})
+		}
+		durations.Samples = append(durations.Samples,
+			Sample{Name: durations.Name + "_bucket", Labels: append(labels[:2:2], Label{"le", "+Inf"}), Value: float64(h.count)},
+			Sample{Name: durations.Name + "_sum", Labels: labels, Value: h.sum},
+			Sample{Name: durations.Name + "_count", Labels: labels, Value: float64(h.count)})
+	}
+
+	ipnOutcomes := map[string]float64{}
+	for o, v := range m.ipn {
+		ipnOutcomes[string(o)] = v
+	}
+
+	return []Family{
+		requests,
+		durations,
+		counter("nowpayments_retries_total", "Retried API calls by route", "route", m.retries),
+		counter("nowpayments_rate_limited_total", "HTTP 429 responses by route", "route", m.rateLimited),
+		counter("nowpayments_auth_total", "JWT authentications by result", "result", m.auths),
+		counter("nowpayments_ipn_total", "IPN notifications by outcome", "outcome", ipnOutcomes),
+	}
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	write := func(format string, args ...interface{}) {
+		c, _ := fmt.Fprintf(bw, format, args...)
+		n += int64(c)
+	}
+
+	for _, f := range m.Gather() {
+		write("# HELP %s %s\n# TYPE %s %s\n", f.Name, f.Help, f.Name, f.Type)
+		for _, s := range f.Samples {
+			write("%s", s.Name)
+			if len(s.Labels) > 0 {
+				labels := make([]string, len(s.Labels))
+				for i, l := range s.Labels {
+					labels[i] = fmt.Sprintf(`%s="%s"`, l.Name, labelEscaper.Replace(l.Value))
+				}
+				write("{%s}", strings.Join(labels, ","))
+			}
+			write(" %s\n", formatFloat(s.Value))
+		}
+	}
+	return n, bw.Flush()
+}
+
+// ServeHTTP exposes the metrics to be scraped by Prometheus
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
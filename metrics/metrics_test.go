@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CIDgravity/go-nowpayments/config"
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/ipn"
+	"github.com/CIDgravity/go-nowpayments/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type rc struct {
+	*strings.Reader
+}
+
+func (*rc) Close() error {
+	return nil
+}
+
+func newResponse(code int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Body:       &rc{strings.NewReader(body)},
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	require.NoError(config.Load(&config.Credentials{APIKey: "key", Server: "http://some.tld"}))
+	c := mocks.NewHTTPClient(t)
+	core.UseClient(c)
+
+	m := New(0.5, 1)
+	retry := func(next core.RoundTrip) core.RoundTrip {
+		return func(c *core.Invocation) error {
+			err := next(c)
+			if c.StatusCode == http.StatusTooManyRequests {
+				err = next(c)
+			}
+			return err
+		}
+	}
+	core.UseMiddleware(m.Middleware(), retry)
+	t.Cleanup(core.ClearMiddleware)
+
+	c.EXPECT().Do(mock.Anything).Return(newResponse(http.StatusOK, `{"message":"OK"}`), nil).Once()
+	c.EXPECT().Do(mock.Anything).Return(newResponse(http.StatusTooManyRequests, `{}`), nil).Once()
+	c.EXPECT().Do(mock.Anything).Return(newResponse(http.StatusOK, `{"message":"OK"}`), nil).Once()
+	c.EXPECT().Do(mock.Anything).Return(newResponse(http.StatusOK, `{"token":"t"}`), nil).Once()
+	c.EXPECT().Do(mock.Anything).Return(newResponse(http.StatusUnauthorized, `{"code":"AUTH_REQUIRED"}`), nil).Once()
+	c.EXPECT().Do(mock.Anything).Return(nil, errors.New("network error")).Once()
+
+	_, err := core.Status()
+	require.NoError(err)
+	_, err = core.Status()
+	require.NoError(err)
+	_, err = core.Authenticate("l", "p")
+	require.NoError(err)
+	_, err = core.Authenticate("l", "p")
+	require.Error(err)
+	_, err = core.Status()
+	require.Error(err)
+
+	for _, o := range []ipn.Outcome{ipn.Received, ipn.Verified, ipn.Received, ipn.Verified, ipn.Duplicate, ipn.Received, ipn.Rejected} {
+		m.ObserveIPN(o)
+	}
+
+	fs := m.Gather()
+	require.Len(fs, 6)
+	assert.Equal("nowpayments_requests_total", fs[0].Name)
+	assert.Equal(Histogram, fs[1].Type)
+
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+	res, err := http.Get(srv.URL)
+	require.NoError(err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(err)
+	out := string(body)
+
+	for _, line := range []string{
+		"# TYPE nowpayments_requests_total counter",
+		`nowpayments_requests_total{route="status",status_class="2xx"} 2`,
+		`nowpayments_requests_total{route="status",status_class="error"} 1`,
+		`nowpayments_requests_total{route="auth",status_class="4xx"} 1`,
+		"# TYPE nowpayments_request_duration_seconds histogram",
+		`nowpayments_request_duration_seconds_bucket{route="status",status_class="2xx",le="0.5"} 2`,
+		`nowpayments_request_duration_seconds_bucket{route="status",status_class="2xx",le="+Inf"} 2`,
+		`nowpayments_request_duration_seconds_count{route="status",status_class="2xx"} 2`,
+		`nowpayments_retries_total{route="status"} 1`,
+		`nowpayments_rate_limited_total{route="status"} 1`,
+		`nowpayments_auth_total{result="failure"} 1`,
+		`nowpayments_auth_total{result="success"} 1`,
+		`nowpayments_ipn_total{outcome="duplicate"} 1`,
+		`nowpayments_ipn_total{outcome="received"} 3`,
+		`nowpayments_ipn_total{outcome="rejected"} 1`,
+		`nowpayments_ipn_total{outcome="verified"} 2`,
+	} {
+		assert.Contains(out, line+"\n")
+	}
+}
@@ -0,0 +1,27 @@
+module github.com/CIDgravity/go-nowpayments/nowpaymentsotel
+
+go 1.20
+
+require (
+	github.com/CIDgravity/go-nowpayments v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.8.4
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/sdk v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/rotisserie/eris v0.5.4 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	golang.org/x/crypto v0.24.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// The library is not released with the core middlewares yet: the module builds against the repository root,
+// the requirement above being set to that release when both are tagged
+replace github.com/CIDgravity/go-nowpayments => ../
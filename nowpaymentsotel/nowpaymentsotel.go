@@ -0,0 +1,213 @@
+// Package nowpaymentsotel traces the NOWPayments API calls and IPN notifications with OpenTelemetry
+//
+// It lives in its own module so that the library doesn't depend on OpenTelemetry
+package nowpaymentsotel
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/ipn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the tracer
+const ScopeName = "github.com/CIDgravity/go-nowpayments/nowpaymentsotel"
+
+// Attributes set on the spans
+const (
+	RouteKey      = attribute.Key("nowpayments.route")
+	PaymentIDKey  = attribute.Key("nowpayments.payment_id")
+	TransferIDKey = attribute.Key("nowpayments.transfer_id")
+	RetriesKey    = attribute.Key("nowpayments.retries")
+	ErrorCodeKey  = attribute.Key("nowpayments.error_code")
+	IPNStatusKey  = attribute.Key("nowpayments.payment_status")
+	methodKey     = attribute.Key("http.request.method")
+	statusCodeKey = attribute.Key("http.response.status_code")
+)
+
+type options struct {
+	tp          trace.TracerProvider
+	propagators propagation.TextMapPropagator
+}
+
+// Option configures the tracing
+type Option func(*options)
+
+// WithTracerProvider sets the tracer provider, the global one by default
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) { o.tp = tp }
+}
+
+// WithPropagators sets the propagators of the trace context, the global ones by default
+func WithPropagators(p propagation.TextMapPropagator) Option {
+	return func(o *options) { o.propagators = p }
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.tp == nil {
+		o.tp = otel.GetTracerProvider()
+	}
+	if o.propagators == nil {
+		o.propagators = otel.GetTextMapPropagator()
+	}
+	return o
+}
+
+// Middleware returns a core middleware tracing each API call with a client span named by its route,
+// e.g. nowpayments.payment-create
+// Register it with core.UseMiddleware, the span being a child of the span of SendParams.Context
+func Middleware(opts ...Option) core.Middleware {
+	o := newOptions(opts)
+	tracer := o.tp.Tracer(ScopeName)
+
+	return func(next core.RoundTrip) core.RoundTrip {
+		return func(c *core.Invocation) error {
+			ctx, span := tracer.Start(c.Context, "nowpayments."+c.RouteName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(RouteKey.String(c.RouteName), methodKey.String(c.Method)))
+			defer span.End()
+
+			c.Context = ctx
+			c.Request = c.Request.WithContext(ctx)
+			o.propagators.Inject(ctx, propagation.HeaderCarrier(c.Request.Header))
+
+			err := next(c)
+
+			span.SetAttributes(RetriesKey.Int(c.Attempt - 1))
+			if c.StatusCode != 0 {
+				span.SetAttributes(statusCodeKey.Int(c.StatusCode))
+			}
+			span.SetAttributes(ids(c, err)...)
+
+			if err != nil {
+				var apiErr *core.APIError
+				if errors.As(err, &apiErr) && apiErr.Code != "" {
+					span.SetAttributes(ErrorCodeKey.String(apiErr.Code))
+				}
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	}
+}
+
+// ids returns the payment and transfer ID attributes of a call, from its path or decoded response
+func ids(c *core.Invocation, err error) []attribute.KeyValue {
+	var key attribute.Key
+	switch {
+	case strings.HasPrefix(c.RouteName, "payment") || c.RouteName == "last-estimate":
+		key = PaymentIDKey
+	case strings.HasPrefix(c.RouteName, "custody-transfer"):
+		key = TransferIDKey
+	default:
+		return nil
+	}
+
+	id := strings.SplitN(c.Params.Path, "/", 2)[0]
+	if id == "" && err == nil {
+		id = responseID(c.Params.Into, key)
+	}
+	if id == "" {
+		return nil
+	}
+	return []attribute.KeyValue{key.String(id)}
+}
+
+// responseID returns the ID of a decoded response, possibly under a result key
+func responseID(into interface{}, key attribute.Key) string {
+	data, err := json.Marshal(into)
+	if err != nil {
+		return ""
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return ""
+	}
+	if r, ok := fields["result"]; ok {
+		fields = nil
+		if json.Unmarshal(r, &fields) != nil {
+			return ""
+		}
+	}
+
+	name := "id"
+	if key == PaymentIDKey {
+		name = "payment_id"
+	}
+	raw, ok := fields[name]
+	if !ok {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(bytes.Trim(raw, `"`))
+}
+
+// Handler traces the IPN notifications with a server span, continuing the trace propagated in the request headers
+// The span is in the context of the request, so in the one passed to the callbacks of an ipn.Handler
+func Handler(h http.Handler, opts ...Option) http.Handler {
+	o := newOptions(opts)
+	tracer := o.tp.Tracer(ScopeName)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := o.propagators.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, "nowpayments.ipn",
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(methodKey.String(r.Method)))
+		defer span.End()
+
+		// Peek at the notification, the body being restored for h
+		payload, err := io.ReadAll(io.LimitReader(r.Body, ipn.MaxPayloadSize))
+		if err == nil {
+			r.Body = io.NopCloser(bytes.NewReader(payload))
+			var n struct {
+				PaymentID     json.Number `json:"payment_id"`
+				PaymentStatus string      `json:"payment_status"`
+			}
+			if json.Unmarshal(payload, &n) == nil {
+				if n.PaymentID != "" {
+					span.SetAttributes(PaymentIDKey.String(n.PaymentID.String()))
+				}
+				if n.PaymentStatus != "" {
+					span.SetAttributes(IPNStatusKey.String(n.PaymentStatus))
+				}
+			}
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttributes(statusCodeKey.Int(sw.status))
+		if sw.status >= 400 {
+			span.SetStatus(codes.Error, http.StatusText(sw.status))
+		}
+	})
+}
+
+// statusWriter keeps the status code written
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,160 @@
+package nowpaymentsotel
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/ipn"
+	"github.com/CIDgravity/go-nowpayments/nowpaymentstest"
+	"github.com/CIDgravity/go-nowpayments/payments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newProvider(t *testing.T) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	t.Cleanup(func() { tp.Shutdown(context.Background()) })
+	return tp, exp
+}
+
+func attrs(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestMiddleware(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	srv := nowpaymentstest.NewServer()
+	t.Cleanup(srv.Close)
+	require.NoError(srv.Use())
+
+	tp, exp := newProvider(t)
+	core.UseMiddleware(Middleware(WithTracerProvider(tp)))
+	t.Cleanup(core.ClearMiddleware)
+
+	p, err := payments.New(&payments.PaymentArgs{
+		PaymentAmount: payments.PaymentAmount{PriceAmount: 100, PriceCurrency: "usd", PayCurrency: "btc", OrderID: "o1"},
+	})
+	require.NoError(err)
+	_, err = payments.Status(p.ID.String())
+	require.NoError(err)
+	_, err = payments.Status("unknown")
+	require.Error(err)
+
+	spans := exp.GetSpans()
+	require.Len(spans, 3)
+
+	create := spans[0]
+	assert.Equal("nowpayments.payment-create", create.Name)
+	assert.Equal(trace.SpanKindClient, create.SpanKind)
+	a := attrs(create)
+	assert.Equal("payment-create", a[RouteKey].AsString())
+	assert.Equal(p.ID.String(), a[PaymentIDKey].AsString())
+	assert.Equal(int64(http.StatusCreated), a[statusCodeKey].AsInt64())
+	assert.Equal(int64(0), a[RetriesKey].AsInt64())
+
+	status := spans[1]
+	assert.Equal("nowpayments.payment-status", status.Name)
+	assert.Equal(p.ID.String(), attrs(status)[PaymentIDKey].AsString())
+	assert.Equal(codes.Unset, status.Status.Code)
+
+	failed := spans[2]
+	assert.Equal(codes.Error, failed.Status.Code)
+	assert.Equal("unknown", attrs(failed)[PaymentIDKey].AsString())
+	assert.NotZero(attrs(failed)[statusCodeKey].AsInt64())
+}
+
+func TestMiddlewareParent(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	srv := nowpaymentstest.NewServer()
+	t.Cleanup(srv.Close)
+	require.NoError(srv.Use())
+
+	tp, exp := newProvider(t)
+	core.UseMiddleware(Middleware(WithTracerProvider(tp), WithPropagators(propagation.TraceContext{})))
+	t.Cleanup(core.ClearMiddleware)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	var st struct {
+		Message string `json:"message"`
+	}
+	require.NoError(core.HTTPSend(&core.SendParams{Context: ctx, RouteName: "status", Into: &st}))
+	parent.End()
+
+	spans := exp.GetSpans()
+	require.Len(spans, 2)
+	assert.Equal("nowpayments.status", spans[0].Name)
+	assert.Equal(parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(parent.SpanContext().TraceID(), spans[0].SpanContext.TraceID())
+}
+
+func TestHandler(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tp, exp := newProvider(t)
+	prop := propagation.TraceContext{}
+
+	var got trace.SpanContext
+	h := &ipn.Handler{Secret: "secret", Callback: func(ctx context.Context, n *ipn.IPNPaymentStatus) error {
+		got = trace.SpanContextFromContext(ctx)
+		return nil
+	}}
+	srv := httptest.NewServer(Handler(h, WithTracerProvider(tp), WithPropagators(prop)))
+	defer srv.Close()
+
+	payload := []byte(`{"payment_id":42,"payment_status":"finished"}`)
+	sig, err := ipn.Sign("secret", payload)
+	require.NoError(err)
+
+	post := func(sig string) {
+		ctx, span := tp.Tracer("test").Start(context.Background(), "sender")
+		defer span.End()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL, bytes.NewReader(payload))
+		require.NoError(err)
+		req.Header.Set(ipn.SignatureHeader, sig)
+		prop.Inject(ctx, propagation.HeaderCarrier(req.Header))
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(err)
+		res.Body.Close()
+	}
+
+	post(sig)
+	spans := exp.GetSpans()
+	require.Len(spans, 2)
+	ipnSpan, sender := spans[0], spans[1]
+	assert.Equal("nowpayments.ipn", ipnSpan.Name)
+	assert.Equal(trace.SpanKindServer, ipnSpan.SpanKind)
+	assert.Equal(sender.SpanContext.TraceID(), ipnSpan.SpanContext.TraceID(), "trace propagated")
+	assert.Equal(ipnSpan.SpanContext.SpanID(), got.SpanID(), "span passed to the callback")
+	a := attrs(ipnSpan)
+	assert.Equal("42", a[PaymentIDKey].AsString())
+	assert.Equal("finished", a[IPNStatusKey].AsString())
+	assert.Equal(int64(http.StatusOK), a[statusCodeKey].AsInt64())
+
+	exp.Reset()
+	post("bad")
+	spans = exp.GetSpans()
+	require.Len(spans, 2)
+	assert.Equal(codes.Error, spans[0].Status.Code)
+	assert.Equal(int64(http.StatusUnauthorized), attrs(spans[0])[statusCodeKey].AsInt64())
+}
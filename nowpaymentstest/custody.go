@@ -0,0 +1,402 @@
+package nowpaymentstest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MasterAccountID is the ID used for the master account in transfers (deposits from master and write-offs)
+const MasterAccountID = "1000000000"
+
+type user struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type balance struct {
+	Amount        float64 `json:"amount"`
+	PendingAmount float64 `json:"pendingAmount"`
+}
+
+type transfer struct {
+	ID        string    `json:"id"`
+	FromSubID string    `json:"from_sub_id"`
+	ToSubID   string    `json:"to_sub_id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Amount    string    `json:"amount"`
+	Currency  string    `json:"currency"`
+}
+
+type conversion struct {
+	ID           string    `json:"id"`
+	Status       string    `json:"status"`
+	FromCurrency string    `json:"from_currency"`
+	ToCurrency   string    `json:"to_currency"`
+	FromAmount   string    `json:"from_amount"`
+	ToAmount     string    `json:"to_amount"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// SetBalance sets the available amount of a currency on a Custody user account
+func (s *Server) SetBalance(userID, currency string, amount float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.balance(userID, currency).Amount = amount
+}
+
+// SetTransferStatus changes the status of a transfer, without moving any funds
+func (s *Server) SetTransferStatus(id, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.transfers {
+		if t.ID == id {
+			t.Status = status
+			t.UpdatedAt = s.now()
+			return nil
+		}
+	}
+	return fmt.Errorf("transfer %s not found", id)
+}
+
+// balance returns the balance of a currency for a user, creating it if needed, the lock must be held
+func (s *Server) balance(userID, currency string) *balance {
+	currency = strings.ToLower(currency)
+	bs, ok := s.balances[userID]
+	if !ok {
+		bs = make(map[string]*balance)
+		s.balances[userID] = bs
+	}
+	b, ok := bs[currency]
+	if !ok {
+		b = &balance{}
+		bs[currency] = b
+	}
+	return b
+}
+
+func (s *Server) user(id string) *user {
+	for _, u := range s.users {
+		if u.ID == id {
+			return u
+		}
+	}
+	return nil
+}
+
+// move transfers funds between two accounts, the master account having no balance limit
+// The lock must be held
+func (s *Server) move(from, to, currency string, amount float64) (*transfer, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be greater than zero")
+	}
+	for _, id := range []string{from, to} {
+		if id != MasterAccountID && s.user(id) == nil {
+			return nil, fmt.Errorf("sub partner %s not found", id)
+		}
+	}
+	if from != MasterAccountID {
+		if b := s.balance(from, currency); b.Amount < amount {
+			return nil, fmt.Errorf("insufficient balance")
+		}
+		s.balance(from, currency).Amount -= amount
+	}
+	if to != MasterAccountID {
+		s.balance(to, currency).Amount += amount
+	}
+
+	now := s.now()
+	t := &transfer{
+		ID:        strconv.FormatInt(s.id(), 10),
+		FromSubID: from,
+		ToSubID:   to,
+		Status:    "FINISHED",
+		Amount:    strconv.FormatFloat(amount, 'f', -1, 64),
+		Currency:  strings.ToLower(currency),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.transfers = append(s.transfers, t)
+	return t, nil
+}
+
+// offset returns the limit and offset query parameters of the custody routes
+func offset(r *request) (int, int) {
+	q := r.URL.Query()
+	return pagination(q.Get("limit"), q.Get("offset"), 10)
+}
+
+func (s *Server) createUser(w http.ResponseWriter, r *request) {
+	var a struct {
+		Name string `json:"name"`
+	}
+	if err := r.decode(&a); err != nil || a.Name == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", "name is required")
+		return
+	}
+
+	now := s.now()
+	u := &user{ID: strconv.FormatInt(s.id(), 10), Name: a.Name, CreatedAt: now, UpdatedAt: now}
+	s.users = append(s.users, u)
+
+	writeResult(w, http.StatusCreated, u)
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *request) {
+	us := []*user{}
+	id := r.URL.Query().Get("id")
+	for _, u := range s.users {
+		if id == "" || u.ID == id {
+			us = append(us, u)
+		}
+	}
+	if strings.EqualFold(r.URL.Query().Get("order"), "desc") {
+		sort.SliceStable(us, func(i, j int) bool { return us[i].CreatedAt.After(us[j].CreatedAt) })
+	}
+
+	limit, off := offset(r)
+	writeResult(w, http.StatusOK, append([]*user{}, paginate(us, limit, off)...))
+}
+
+func (s *Server) getBalance(w http.ResponseWriter, r *request) {
+	if s.user(r.id) == nil {
+		writeError(w, http.StatusNotFound, "SUB_PARTNER_NOT_FOUND", "Sub partner not found")
+		return
+	}
+
+	writeResult(w, http.StatusOK, map[string]interface{}{
+		"subPartnerId": r.id,
+		"balances":     s.balances[r.id],
+	})
+}
+
+func (s *Server) createTransfer(w http.ResponseWriter, r *request) {
+	var a struct {
+		FromID   string  `json:"from_id"`
+		ToID     string  `json:"to_id"`
+		Amount   float64 `json:"amount"`
+		Currency string  `json:"currency"`
+	}
+	if err := r.decode(&a); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	t, err := s.move(a.FromID, a.ToID, a.Currency, a.Amount)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	writeResult(w, http.StatusCreated, t)
+}
+
+func (s *Server) getTransfer(w http.ResponseWriter, r *request) {
+	for _, t := range s.transfers {
+		if t.ID == r.id {
+			writeResult(w, http.StatusOK, t)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "TRANSFER_NOT_FOUND", "Transfer not found")
+}
+
+func (s *Server) listTransfers(w http.ResponseWriter, r *request) {
+	q := r.URL.Query()
+	ts := []*transfer{}
+	for _, t := range s.transfers {
+		if id := q.Get("id"); id != "" && t.ID != id {
+			continue
+		}
+		if st := q.Get("status"); st != "" && !strings.EqualFold(t.Status, st) {
+			continue
+		}
+		ts = append(ts, t)
+	}
+	if strings.EqualFold(q.Get("order"), "desc") {
+		sort.SliceStable(ts, func(i, j int) bool { return ts[i].CreatedAt.After(ts[j].CreatedAt) })
+	}
+
+	limit, off := offset(r)
+	writeResult(w, http.StatusOK, append([]*transfer{}, paginate(ts, limit, off)...))
+}
+
+// depositArgs are the arguments of the deposit and write-off routes
+type depositArgs struct {
+	Currency       string  `json:"currency"`
+	Amount         float64 `json:"amount"`
+	SubPartnerID   string  `json:"sub_partner_id"`
+	IpnCallbackURL string  `json:"ipn_callback_url"`
+}
+
+func (s *Server) createDepositPayment(w http.ResponseWriter, r *request) {
+	a := &depositArgs{}
+	if err := r.decode(a); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+	if s.user(a.SubPartnerID) == nil {
+		writeError(w, http.StatusNotFound, "SUB_PARTNER_NOT_FOUND", "Sub partner not found")
+		return
+	}
+
+	p, err := s.newPayment(&paymentArgs{
+		PriceAmount:   a.Amount,
+		PriceCurrency: a.Currency,
+		PayCurrency:   a.Currency,
+		PayAmount:     a.Amount,
+		CallbackURL:   a.IpnCallbackURL,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+	p.SubPartnerID = a.SubPartnerID
+
+	writeResult(w, http.StatusCreated, p.render(true))
+}
+
+func (s *Server) listCustodyPayments(w http.ResponseWriter, r *request) {
+	q := r.URL.Query()
+	ps, err := filterPayments(s.payments, q.Get("date_from"), q.Get("date_to"), func(p *payment) bool {
+		if p.SubPartnerID == "" {
+			return false
+		}
+		if id := q.Get("sub_partner_id"); id != "" && p.SubPartnerID != id {
+			return false
+		}
+		if id := q.Get("id"); id != "" && strconv.FormatInt(p.ID, 10) != id {
+			return false
+		}
+		if c := q.Get("pay_currency"); c != "" && !strings.EqualFold(p.PayCurrency, c) {
+			return false
+		}
+		if st := q.Get("status"); st != "" && p.Status != st {
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	limit, page := pagination(q.Get("limit"), q.Get("page"), 10)
+	data := []interface{}{}
+	for _, p := range paginate(ps, limit, page*limit) {
+		data = append(data, p.render(true))
+	}
+
+	writeResult(w, http.StatusOK, data)
+}
+
+func (s *Server) createMasterDeposit(w http.ResponseWriter, r *request) {
+	a := &depositArgs{}
+	if err := r.decode(a); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	t, err := s.move(MasterAccountID, a.SubPartnerID, a.Currency, a.Amount)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	writeResult(w, http.StatusCreated, t)
+}
+
+func (s *Server) createWriteOff(w http.ResponseWriter, r *request) {
+	a := &depositArgs{}
+	if err := r.decode(a); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	t, err := s.move(a.SubPartnerID, MasterAccountID, a.Currency, a.Amount)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	writeResult(w, http.StatusCreated, t)
+}
+
+// createConversion records a conversion of the master account balance, which the server does not track
+func (s *Server) createConversion(w http.ResponseWriter, r *request) {
+	var a struct {
+		Amount       float64 `json:"amount"`
+		FromCurrency string  `json:"from_currency"`
+		ToCurrency   string  `json:"to_currency"`
+	}
+	if err := r.decode(&a); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	to, err := convert(a.Amount, a.FromCurrency, a.ToCurrency)
+	if err != nil || a.Amount <= 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", "invalid conversion")
+		return
+	}
+
+	now := s.now()
+	c := &conversion{
+		ID:           strconv.FormatInt(s.id(), 10),
+		Status:       "FINISHED",
+		FromCurrency: strings.ToLower(a.FromCurrency),
+		ToCurrency:   strings.ToLower(a.ToCurrency),
+		FromAmount:   strconv.FormatFloat(a.Amount, 'f', -1, 64),
+		ToAmount:     strconv.FormatFloat(to, 'f', -1, 64),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.conversions = append(s.conversions, c)
+
+	writeResult(w, http.StatusCreated, c)
+}
+
+func (s *Server) getConversion(w http.ResponseWriter, r *request) {
+	for _, c := range s.conversions {
+		if c.ID == r.id {
+			writeResult(w, http.StatusOK, c)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "CONVERSION_NOT_FOUND", "Conversion not found")
+}
+
+func (s *Server) listConversions(w http.ResponseWriter, r *request) {
+	q := r.URL.Query()
+	cs := []*conversion{}
+	for _, c := range s.conversions {
+		if id := q.Get("id"); id != "" && c.ID != id {
+			continue
+		}
+		if st := q.Get("status"); st != "" && !strings.EqualFold(c.Status, st) {
+			continue
+		}
+		if fc := q.Get("from_currency"); fc != "" && !strings.EqualFold(c.FromCurrency, fc) {
+			continue
+		}
+		if tc := q.Get("to_currency"); tc != "" && !strings.EqualFold(c.ToCurrency, tc) {
+			continue
+		}
+		cs = append(cs, c)
+	}
+
+	limit, off := offset(r)
+	writeResult(w, http.StatusOK, append([]*conversion{}, paginate(cs, limit, off)...))
+}
@@ -0,0 +1,512 @@
+package nowpaymentstest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts used by the payments routes for timestamps and date-only filters
+const (
+	timeLayout = "2006-01-02T15:04:05.000Z"
+	dateLayout = "2006-01-02"
+)
+
+// rates are the USD values of the currencies known by the server
+var rates = map[string]float64{
+	"usd":       1,
+	"eur":       1.1,
+	"btc":       30000,
+	"eth":       2000,
+	"ltc":       80,
+	"xmr":       150,
+	"trx":       0.1,
+	"usdtbsc":   1,
+	"usdttrc20": 1,
+	"usddtrc20": 1,
+	"usdterc20": 1,
+}
+
+// minAmountUSD is the minimum payment amount, in USD
+const minAmountUSD = 1.0
+
+func convert(amount float64, from, to string) (float64, error) {
+	rf, ok := rates[strings.ToLower(from)]
+	if !ok {
+		return 0, fmt.Errorf("currency %s not found", from)
+	}
+	rt, ok := rates[strings.ToLower(to)]
+	if !ok {
+		return 0, fmt.Errorf("currency %s not found", to)
+	}
+	return amount * rf / rt, nil
+}
+
+// payment is a payment stored by the server
+type payment struct {
+	ID               int64
+	InvoiceID        int64
+	PurchaseID       int64
+	Status           string
+	PayAddress       string
+	PriceAmount      float64
+	PriceCurrency    string
+	PayAmount        float64
+	PayCurrency      string
+	ActuallyPaid     float64
+	OrderID          string
+	OrderDescription string
+	CallbackURL      string
+	Case             string
+	OutcomeAmount    float64
+	OutcomeCurrency  string
+	SubPartnerID     string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// render returns the JSON representation of the payment, the payment ID being a string or a number
+// depending on the route
+func (p *payment) render(stringID bool) map[string]interface{} {
+	m := map[string]interface{}{
+		"payment_id":        p.ID,
+		"invoice_id":        p.InvoiceID,
+		"purchase_id":       p.PurchaseID,
+		"payment_status":    p.Status,
+		"pay_address":       p.PayAddress,
+		"price_amount":      p.PriceAmount,
+		"price_currency":    p.PriceCurrency,
+		"pay_amount":        p.PayAmount,
+		"pay_currency":      p.PayCurrency,
+		"actually_paid":     p.ActuallyPaid,
+		"order_id":          p.OrderID,
+		"order_description": p.OrderDescription,
+		"ipn_callback_url":  p.CallbackURL,
+		"outcome_amount":    p.OutcomeAmount,
+		"outcome_currency":  p.OutcomeCurrency,
+		"created_at":        p.CreatedAt.UTC().Format(timeLayout),
+		"updated_at":        p.UpdatedAt.UTC().Format(timeLayout),
+		"type":              "crypto2crypto",
+	}
+	if stringID {
+		m["payment_id"] = strconv.FormatInt(p.ID, 10)
+	}
+	if p.InvoiceID == 0 {
+		m["invoice_id"] = nil
+	}
+	return m
+}
+
+// invoice is an invoice stored by the server
+type invoice struct {
+	ID               int64
+	PriceAmount      float64
+	PriceCurrency    string
+	PayCurrency      string
+	OrderID          string
+	OrderDescription string
+	CallbackURL      string
+	SuccessURL       string
+	CancelURL        string
+	CreatedAt        time.Time
+}
+
+// paymentArgs are the arguments of the payment creation routes
+type paymentArgs struct {
+	InvoiceID        string  `json:"iid"`
+	PriceAmount      float64 `json:"price_amount"`
+	PriceCurrency    string  `json:"price_currency"`
+	PayCurrency      string  `json:"pay_currency"`
+	PayAmount        float64 `json:"pay_amount"`
+	CallbackURL      string  `json:"ipn_callback_url"`
+	OrderID          string  `json:"order_id"`
+	OrderDescription string  `json:"order_description"`
+	PurchaseID       string  `json:"purchase_id"`
+	Case             string  `json:"case"`
+	SuccessURL       string  `json:"success_url"`
+	CancelURL        string  `json:"cancel_url"`
+}
+
+// Payment returns the stored payment as returned by the payment status route, or nil if not found
+func (s *Server) Payment(id string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p := s.payment(id); p != nil {
+		return p.render(false)
+	}
+	return nil
+}
+
+// SetPaymentStatus changes the status of a payment, and the actually paid amount when not negative
+// Finishing a deposit made with custody.NewDepositWithPayment credits the Custody user account
+func (s *Server) SetPaymentStatus(id, status string, actuallyPaid float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.payment(id)
+	if p == nil {
+		return fmt.Errorf("payment %s not found", id)
+	}
+
+	s.setPaymentStatus(p, status, actuallyPaid)
+	return nil
+}
+
+// setPaymentStatus changes the status of a payment, crediting the Custody user account once a deposit
+// is finished, the lock must be held
+func (s *Server) setPaymentStatus(p *payment, status string, actuallyPaid float64) {
+	if actuallyPaid >= 0 {
+		p.ActuallyPaid = actuallyPaid
+	}
+	if p.SubPartnerID != "" && status == "finished" && p.Status != "finished" {
+		s.balance(p.SubPartnerID, p.PayCurrency).Amount += p.ActuallyPaid
+	}
+	if status == "finished" {
+		p.OutcomeAmount = p.ActuallyPaid
+	}
+	p.Status = status
+	p.UpdatedAt = s.now()
+}
+
+// payment returns the stored payment, the lock must be held
+func (s *Server) payment(id string) *payment {
+	for _, p := range s.payments {
+		if strconv.FormatInt(p.ID, 10) == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// newPayment creates and stores a payment, the lock must be held
+func (s *Server) newPayment(a *paymentArgs) (*payment, error) {
+	if a.PayCurrency == "" {
+		return nil, fmt.Errorf("pay_currency is required")
+	}
+
+	payAmount := a.PayAmount
+	if payAmount == 0 {
+		var err error
+		payAmount, err = convert(a.PriceAmount, a.PriceCurrency, a.PayCurrency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	usd, err := convert(payAmount, a.PayCurrency, "usd")
+	if err != nil {
+		return nil, err
+	}
+	if usd < minAmountUSD {
+		return nil, fmt.Errorf("amountTo is too small")
+	}
+
+	now := s.now()
+	p := &payment{
+		ID:               s.id(),
+		Status:           "waiting",
+		PriceAmount:      a.PriceAmount,
+		PriceCurrency:    a.PriceCurrency,
+		PayAmount:        payAmount,
+		PayCurrency:      a.PayCurrency,
+		OrderID:          a.OrderID,
+		OrderDescription: a.OrderDescription,
+		CallbackURL:      a.CallbackURL,
+		Case:             a.Case,
+		OutcomeCurrency:  a.PayCurrency,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	p.PayAddress = fmt.Sprintf("addr-%s-%d", strings.ToLower(a.PayCurrency), p.ID)
+	p.PurchaseID = p.ID + 1000
+
+	s.payments = append(s.payments, p)
+	if s.created != nil {
+		s.created(p)
+	}
+	return p, nil
+}
+
+func (s *Server) currencies(w http.ResponseWriter, r *request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"currencies": currencyNames()})
+}
+
+func (s *Server) selectedCurrencies(w http.ResponseWriter, r *request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"selectedCurrencies": currencyNames()})
+}
+
+func currencyNames() []string {
+	cs := make([]string, 0, len(rates))
+	for c := range rates {
+		if c != "usd" && c != "eur" {
+			cs = append(cs, c)
+		}
+	}
+	sort.Strings(cs)
+	return cs
+}
+
+func (s *Server) estimate(w http.ResponseWriter, r *request) {
+	q := r.URL.Query()
+	amount, err := strconv.ParseFloat(q.Get("amount"), 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", "amount must be a number")
+		return
+	}
+
+	est, err := convert(amount, q.Get("currency_from"), q.Get("currency_to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"currency_from":    q.Get("currency_from"),
+		"currency_to":      q.Get("currency_to"),
+		"amount_from":      amount,
+		"estimated_amount": strconv.FormatFloat(est, 'f', -1, 64),
+	})
+}
+
+func (s *Server) minAmount(w http.ResponseWriter, r *request) {
+	q := r.URL.Query()
+	min, err := convert(minAmountUSD, "usd", q.Get("currency_from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	res := map[string]interface{}{
+		"currency_from": q.Get("currency_from"),
+		"currency_to":   q.Get("currency_to"),
+		"min_amount":    min,
+	}
+	if fiat := q.Get("fiat_equivalent"); fiat != "" {
+		fe, err := convert(minAmountUSD, "usd", fiat)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+			return
+		}
+		res["fiat_equivalent"] = fe
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) createPayment(w http.ResponseWriter, r *request) {
+	a := &paymentArgs{}
+	if err := r.decode(a); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	p, err := s.newPayment(a)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, p.render(true))
+}
+
+func (s *Server) paymentStatus(w http.ResponseWriter, r *request) {
+	p := s.payment(r.id)
+	if p == nil {
+		writeError(w, http.StatusNotFound, "PAYMENT_NOT_FOUND", "Payment not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p.render(false))
+}
+
+func (s *Server) lastEstimate(w http.ResponseWriter, r *request) {
+	p := s.payment(r.id)
+	if p == nil {
+		writeError(w, http.StatusNotFound, "PAYMENT_NOT_FOUND", "Payment not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":                       strconv.FormatInt(p.ID, 10),
+		"token_id":                 fmt.Sprintf("tok-%d", p.ID),
+		"pay_amount":               p.PayAmount,
+		"expiration_estimate_date": s.now().Add(20 * time.Minute).UTC().Format(timeLayout),
+	})
+}
+
+func (s *Server) listPayments(w http.ResponseWriter, r *request) {
+	q := r.URL.Query()
+	ps, err := filterPayments(s.payments, q.Get("dateFrom"), q.Get("dateTo"), func(p *payment) bool {
+		return p.SubPartnerID == ""
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	if q.Get("sortBy") == "created_at" && q.Get("orderBy") == "desc" {
+		sort.SliceStable(ps, func(i, j int) bool { return ps[i].CreatedAt.After(ps[j].CreatedAt) })
+	}
+
+	limit, page := pagination(q.Get("limit"), q.Get("page"), 10)
+	data := []interface{}{}
+	for _, p := range paginate(ps, limit, page*limit) {
+		data = append(data, p.render(false))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data":       data,
+		"limit":      limit,
+		"page":       page,
+		"pagesCount": (len(ps) + limit - 1) / limit,
+		"total":      len(ps),
+	})
+}
+
+func (s *Server) createInvoice(w http.ResponseWriter, r *request) {
+	a := &paymentArgs{}
+	if err := r.decode(a); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+	if _, err := convert(a.PriceAmount, a.PriceCurrency, "usd"); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	inv := &invoice{
+		ID:               s.id(),
+		PriceAmount:      a.PriceAmount,
+		PriceCurrency:    a.PriceCurrency,
+		PayCurrency:      a.PayCurrency,
+		OrderID:          a.OrderID,
+		OrderDescription: a.OrderDescription,
+		CallbackURL:      a.CallbackURL,
+		SuccessURL:       a.SuccessURL,
+		CancelURL:        a.CancelURL,
+		CreatedAt:        s.now(),
+	}
+	s.invoices[inv.ID] = inv
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":                strconv.FormatInt(inv.ID, 10),
+		"token_id":          fmt.Sprintf("tok-%d", inv.ID),
+		"order_id":          inv.OrderID,
+		"order_description": inv.OrderDescription,
+		"price_amount":      strconv.FormatFloat(inv.PriceAmount, 'f', -1, 64),
+		"price_currency":    inv.PriceCurrency,
+		"pay_currency":      inv.PayCurrency,
+		"ipn_callback_url":  inv.CallbackURL,
+		"invoice_url":       fmt.Sprintf("%s/invoice/?iid=%d", s.URL, inv.ID),
+		"success_url":       inv.SuccessURL,
+		"cancel_url":        inv.CancelURL,
+		"created_at":        inv.CreatedAt.UTC().Format(timeLayout),
+		"updated_at":        inv.CreatedAt.UTC().Format(timeLayout),
+	})
+}
+
+func (s *Server) createInvoicePayment(w http.ResponseWriter, r *request) {
+	a := &paymentArgs{}
+	if err := r.decode(a); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	iid, _ := strconv.ParseInt(a.InvoiceID, 10, 64)
+	inv, ok := s.invoices[iid]
+	if !ok {
+		writeError(w, http.StatusNotFound, "INVOICE_NOT_FOUND", "Invoice not found")
+		return
+	}
+
+	pa := &paymentArgs{
+		PriceAmount:      inv.PriceAmount,
+		PriceCurrency:    inv.PriceCurrency,
+		PayCurrency:      a.PayCurrency,
+		OrderID:          inv.OrderID,
+		OrderDescription: inv.OrderDescription,
+		CallbackURL:      inv.CallbackURL,
+	}
+	if a.OrderDescription != "" {
+		pa.OrderDescription = a.OrderDescription
+	}
+
+	p, err := s.newPayment(pa)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+	p.InvoiceID = inv.ID
+
+	writeJSON(w, http.StatusCreated, p.render(true))
+}
+
+// filterPayments returns the payments created in the date range and accepted by keep
+func filterPayments(ps []*payment, from, to string, keep func(*payment) bool) ([]*payment, error) {
+	df, err := parseDate(from)
+	if err != nil {
+		return nil, err
+	}
+	dt, err := parseDate(to)
+	if err != nil {
+		return nil, err
+	}
+	if !dt.IsZero() && len(to) == len(dateLayout) {
+		// A date only includes the whole day
+		dt = dt.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	res := []*payment{}
+	for _, p := range ps {
+		if !keep(p) {
+			continue
+		}
+		if !df.IsZero() && p.CreatedAt.Before(df) {
+			continue
+		}
+		if !dt.IsZero() && p.CreatedAt.After(dt) {
+			continue
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+// parseDate parses a date sent as a date only or as a RFC3339 timestamp
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(dateLayout, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// pagination returns the limit and page (or offset) query parameters, with default values
+func pagination(limit, page string, def int) (int, int) {
+	l, err := strconv.Atoi(limit)
+	if err != nil || l <= 0 {
+		l = def
+	}
+	p, err := strconv.Atoi(page)
+	if err != nil || p < 0 {
+		p = 0
+	}
+	return l, p
+}
+
+func paginate[T any](all []T, limit, offset int) []T {
+	if offset >= len(all) {
+		return nil
+	}
+	end := offset + limit
+	if end > len(all) {
+		end = len(all)
+	}
+	return all[offset:end]
+}
@@ -0,0 +1,437 @@
+// Package nowpaymentstest provides an in-process fake NOWPayments API server for integration tests.
+//
+// The server implements every route used by the library, keeps its state in memory (a payment
+// created with payments.New shows up in payments.List), validates the API key and the JWT tokens,
+// and lets tests inject errors and latency per route:
+//
+//	srv := nowpaymentstest.NewServer()
+//	defer srv.Close()
+//	srv.Use()
+//
+//	p, err := payments.New(&payments.PaymentArgs{...})
+package nowpaymentstest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/config"
+	"github.com/CIDgravity/go-nowpayments/core"
+)
+
+// Default credentials accepted by the server
+const (
+	APIKey       = "test-api-key"
+	IPNSecretKey = "test-ipn-secret"
+	Login        = "test@nowpayments.test"
+	Password     = "test-password"
+)
+
+// Fault is an error returned by the server instead of handling a request
+type Fault struct {
+	// StatusCode, Code and Message are the HTTP status (500 when not set) and the error details sent back
+	StatusCode int
+	Code       string
+	Message    string
+	// Drop closes the connection without answering, which the client sees as a network error
+	Drop bool
+	// Times is the number of requests the fault applies to, 0 meaning every request
+	Times int
+}
+
+// Server is a fake NOWPayments API server
+type Server struct {
+	*httptest.Server
+
+	// Credentials accepted by the server, default to the package constants
+	APIKey   string
+	Login    string
+	Password string
+
+	mu       sync.Mutex
+	now      func() time.Time
+	created  func(*payment)
+	nextID   int64
+	tokens   map[string]bool
+	faults   map[string]*Fault
+	latency  map[string]time.Duration
+	requests map[string]int
+
+	payments    []*payment
+	invoices    map[int64]*invoice
+	plans       []*plan
+	recurring   []*recurring
+	users       []*user
+	balances    map[string]map[string]*balance
+	transfers   []*transfer
+	conversions []*conversion
+}
+
+// NewServer starts a fake NOWPayments API server, which must be closed when done
+func NewServer() *Server {
+	s := &Server{
+		APIKey:   APIKey,
+		Login:    Login,
+		Password: Password,
+		now:      time.Now,
+		nextID:   5000000000,
+		tokens:   make(map[string]bool),
+		faults:   make(map[string]*Fault),
+		latency:  make(map[string]time.Duration),
+		requests: make(map[string]int),
+		invoices: make(map[int64]*invoice),
+		balances: make(map[string]map[string]*balance),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// BaseURL returns the base URL to use with core.UseBaseURL
+func (s *Server) BaseURL() core.BaseURL {
+	return core.BaseURL(s.URL + "/v1")
+}
+
+// Use configures the library to send its requests to the server, with valid credentials
+func (s *Server) Use() error {
+	err := config.Load(&config.Credentials{
+		APIKey:       s.APIKey,
+		IPNSecretKey: IPNSecretKey,
+		Login:        s.Login,
+		Password:     s.Password,
+		Server:       string(s.BaseURL()),
+	})
+	if err != nil {
+		return err
+	}
+
+	core.UseBaseURL(s.BaseURL())
+	core.UseClient(core.NewHTTPClient())
+	return nil
+}
+
+// Fail makes the server answer requests to a route (core route name, like "payment-create") with the fault
+func (s *Server) Fail(route string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults[route] = &f
+}
+
+// Delay makes the server wait before handling requests to a route, or all routes if route is empty
+func (s *Server) Delay(route string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency[route] = d
+}
+
+// Reset removes all faults and delays
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = make(map[string]*Fault)
+	s.latency = make(map[string]time.Duration)
+}
+
+// Requests returns the number of requests received on a route
+func (s *Server) Requests(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[route]
+}
+
+// request is an incoming request matched to a route
+type request struct {
+	*http.Request
+	route string
+	// id is the path parameter following the route path, if any
+	id  string
+	jwt bool
+}
+
+// decode reads the JSON request body into v
+func (r *request) decode(v interface{}) error {
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+type handler func(s *Server, w http.ResponseWriter, r *request)
+
+// match returns the route name, the path parameter and whether a JWT is required for the request
+func match(method, path string) (route, id string, jwt bool) {
+	seg := strings.Split(strings.Trim(path, "/"), "/")
+	if len(seg) == 0 {
+		return "", "", false
+	}
+
+	param := func(n int) string {
+		if len(seg) > n {
+			return strings.Join(seg[n:], "/")
+		}
+		return ""
+	}
+
+	switch {
+	case path == "/auth" && method == http.MethodPost:
+		return "auth", "", false
+	case path == "/status" && method == http.MethodGet:
+		return "status", "", false
+	case path == "/currencies" && method == http.MethodGet:
+		return "currencies", "", false
+	case path == "/merchant/coins" && method == http.MethodGet:
+		return "selected-currencies", "", false
+	case path == "/estimate" && method == http.MethodGet:
+		return "estimate", "", false
+	case path == "/min-amount" && method == http.MethodGet:
+		return "min-amount", "", false
+	case path == "/invoice" && method == http.MethodPost:
+		return "invoice-create", "", false
+	case path == "/invoice-payment" && method == http.MethodPost:
+		return "invoice-payment", "", false
+	case path == "/payment/" && method == http.MethodGet:
+		return "payments-list", "", true
+	case seg[0] == "payment":
+		switch {
+		case path == "/payment" && method == http.MethodPost:
+			return "payment-create", "", false
+		case len(seg) == 3 && seg[2] == "update-merchant-estimate" && method == http.MethodPost:
+			return "last-estimate", seg[1], false
+		case len(seg) == 2 && method == http.MethodGet:
+			return "payment-status", seg[1], false
+		}
+	case seg[0] == "subscriptions" && len(seg) > 1 && seg[1] == "plans":
+		switch {
+		case len(seg) == 2 && method == http.MethodPost:
+			return "subscription-create", "", true
+		case len(seg) == 2 && method == http.MethodGet:
+			return "subscription-list", "", false
+		case len(seg) == 3 && method == http.MethodPatch:
+			return "subscription-update", seg[2], true
+		case len(seg) == 3 && method == http.MethodGet:
+			return "subscription-single", seg[2], false
+		}
+	case seg[0] == "subscriptions":
+		switch {
+		case len(seg) == 1 && method == http.MethodPost:
+			// subscription-create-email and recurring-payment-create share the same route
+			return "recurring-payment-create", "", true
+		case len(seg) == 1 && method == http.MethodGet:
+			return "recurring-payment-list", "", false
+		case len(seg) == 2 && method == http.MethodGet:
+			return "recurring-payment-single", seg[1], false
+		case len(seg) == 2 && method == http.MethodDelete:
+			return "recurring-payment-delete", seg[1], true
+		}
+	case seg[0] == "sub-partner":
+		sub := ""
+		if len(seg) > 1 {
+			sub = seg[1]
+		}
+		switch {
+		case sub == "" && method == http.MethodGet:
+			return "custody-list-users", "", true
+		case sub == "balance" && len(seg) == 2 && method == http.MethodPost:
+			return "custody-create-account", "", true
+		case sub == "balance" && method == http.MethodGet:
+			return "custody-account-balance", param(2), false
+		case sub == "transfer" && len(seg) == 2 && method == http.MethodPost:
+			return "custody-transfer-create", "", true
+		case sub == "transfer" && method == http.MethodGet:
+			return "custody-transfer-single", param(2), true
+		case sub == "transfers" && method == http.MethodGet:
+			return "custody-list-transfers", "", true
+		case sub == "payment" && method == http.MethodPost:
+			return "custody-deposit-with-payment", "", true
+		case sub == "payments" && method == http.MethodGet:
+			return "custody-payment-list", "", true
+		case sub == "deposit" && method == http.MethodPost:
+			return "custody-deposit-from-master", "", true
+		case sub == "write-off" && method == http.MethodPost:
+			return "custody-write-off-to-master", "", true
+		}
+	case seg[0] == "conversion":
+		switch {
+		case len(seg) == 1 && method == http.MethodPost:
+			return "custody-conversion-create", "", true
+		case len(seg) == 1 && method == http.MethodGet:
+			return "custody-conversion-list", "", true
+		case len(seg) == 2 && method == http.MethodGet:
+			return "custody-conversion-single", seg[1], true
+		}
+	}
+
+	return "", "", false
+}
+
+var handlers = map[string]handler{
+	"auth":                (*Server).auth,
+	"status":              (*Server).status,
+	"currencies":          (*Server).currencies,
+	"selected-currencies": (*Server).selectedCurrencies,
+	"estimate":            (*Server).estimate,
+	"min-amount":          (*Server).minAmount,
+
+	"invoice-create":  (*Server).createInvoice,
+	"invoice-payment": (*Server).createInvoicePayment,
+	"last-estimate":   (*Server).lastEstimate,
+	"payment-create":  (*Server).createPayment,
+	"payment-status":  (*Server).paymentStatus,
+	"payments-list":   (*Server).listPayments,
+
+	"subscription-create":      (*Server).createPlan,
+	"subscription-update":      (*Server).updatePlan,
+	"subscription-single":      (*Server).getPlan,
+	"subscription-list":        (*Server).listPlans,
+	"recurring-payment-create": (*Server).createRecurring,
+	"recurring-payment-single": (*Server).getRecurring,
+	"recurring-payment-list":   (*Server).listRecurring,
+	"recurring-payment-delete": (*Server).deleteRecurring,
+
+	"custody-create-account":       (*Server).createUser,
+	"custody-account-balance":      (*Server).getBalance,
+	"custody-transfer-create":      (*Server).createTransfer,
+	"custody-list-transfers":       (*Server).listTransfers,
+	"custody-transfer-single":      (*Server).getTransfer,
+	"custody-list-users":           (*Server).listUsers,
+	"custody-deposit-with-payment": (*Server).createDepositPayment,
+	"custody-deposit-from-master":  (*Server).createMasterDeposit,
+	"custody-payment-list":         (*Server).listCustodyPayments,
+	"custody-write-off-to-master":  (*Server).createWriteOff,
+	"custody-conversion-create":    (*Server).createConversion,
+	"custody-conversion-single":    (*Server).getConversion,
+	"custody-conversion-list":      (*Server).listConversions,
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, hr *http.Request) {
+	path := strings.TrimPrefix(hr.URL.Path, "/v1")
+	route, id, jwt := match(hr.Method, path)
+	h, ok := handlers[route]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Cannot "+hr.Method+" "+hr.URL.Path)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests[route]++
+	d, ok := s.latency[route]
+	if !ok {
+		d = s.latency[""]
+	}
+	f := s.faults[route]
+	if f != nil && f.Times > 0 {
+		f.Times--
+		if f.Times == 0 {
+			delete(s.faults, route)
+		}
+	}
+	s.mu.Unlock()
+
+	if d > 0 {
+		select {
+		case <-time.After(d):
+		case <-hr.Context().Done():
+			return
+		}
+	}
+
+	if f != nil {
+		if f.Drop {
+			if hj, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hj.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+		code := f.StatusCode
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		writeError(w, code, f.Code, f.Message)
+		return
+	}
+
+	if hr.Header.Get("X-API-KEY") != s.APIKey {
+		writeError(w, http.StatusForbidden, "INVALID_API_KEY", "Invalid api key")
+		return
+	}
+
+	if jwt && !s.validToken(hr.Header.Get("Authorization")) {
+		writeError(w, http.StatusUnauthorized, "AUTH_REQUIRED", "Authorization header is empty (Bearer JWTtoken is required)")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h(s, w, &request{Request: hr, route: route, id: id, jwt: jwt})
+}
+
+func (s *Server) validToken(header string) bool {
+	tok := strings.TrimPrefix(header, "Bearer ")
+	if tok == "" || tok == header {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[tok]
+}
+
+// id returns a new unique identifier, the lock must be held
+func (s *Server) id() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *Server) auth(w http.ResponseWriter, r *request) {
+	var c struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := r.decode(&c); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	if c.Email != s.Login || c.Password != s.Password {
+		writeError(w, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid credentials")
+		return
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	tok := hex.EncodeToString(b)
+	s.tokens[tok] = true
+
+	writeJSON(w, http.StatusOK, map[string]string{"token": tok})
+}
+
+func (s *Server) status(w http.ResponseWriter, r *request) {
+	writeJSON(w, http.StatusOK, map[string]string{"message": "OK"})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeResult writes v under the result key, as done by the custody and subscription routes
+func writeResult(w http.ResponseWriter, code int, v interface{}) {
+	writeJSON(w, code, map[string]interface{}{"result": v})
+}
+
+func writeError(w http.ResponseWriter, code int, errCode, message string) {
+	writeJSON(w, code, map[string]interface{}{
+		"statusCode": code,
+		"code":       errCode,
+		"message":    message,
+	})
+}
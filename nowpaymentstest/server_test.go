@@ -0,0 +1,200 @@
+package nowpaymentstest_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/config"
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/currencies"
+	"github.com/CIDgravity/go-nowpayments/custody"
+	"github.com/CIDgravity/go-nowpayments/nowpaymentstest"
+	"github.com/CIDgravity/go-nowpayments/payments"
+	recurringPayment "github.com/CIDgravity/go-nowpayments/recurring_payments"
+	"github.com/CIDgravity/go-nowpayments/subscriptions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer(t *testing.T) *nowpaymentstest.Server {
+	srv := nowpaymentstest.NewServer()
+	t.Cleanup(srv.Close)
+	require.NoError(t, srv.Use())
+	return srv
+}
+
+func TestPayments(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	srv := newServer(t)
+
+	st, err := core.Status()
+	require.NoError(err)
+	assert.Equal("OK", st)
+
+	cs, err := currencies.Selected()
+	require.NoError(err)
+	assert.Contains(cs, "btc")
+
+	p, err := payments.New(&payments.PaymentArgs{
+		PaymentAmount: payments.PaymentAmount{
+			PriceAmount:   100,
+			PriceCurrency: "usd",
+			PayCurrency:   "btc",
+			OrderID:       "order-1",
+		},
+	})
+	require.NoError(err)
+	assert.Equal("waiting", p.Status)
+	assert.InDelta(100.0/30000, p.PayAmount.Float64(), 1e-12)
+
+	ps, err := payments.List(nil)
+	require.NoError(err)
+	if assert.Len(ps, 1) {
+		assert.Equal(p.ID, ps[0].ID)
+		assert.Equal("order-1", ps[0].OrderID)
+	}
+
+	ps, err = payments.List(&payments.ListOption{DateFrom: time.Now(), DateTo: time.Now()})
+	require.NoError(err)
+	assert.Len(ps, 1, "date only filters cover the whole day")
+
+	require.NoError(srv.SetPaymentStatus(p.ID.String(), "finished", p.PayAmount.Float64()))
+	s, err := payments.Status(p.ID.String())
+	require.NoError(err)
+	assert.Equal("finished", s.Status)
+
+	inv, err := payments.NewInvoice(&payments.InvoiceArgs{
+		PaymentAmount: payments.PaymentAmount{PriceAmount: 10, PriceCurrency: "eur", OrderID: "order-2"},
+	})
+	require.NoError(err)
+	ip, err := payments.NewFromInvoice(&payments.InvoicePaymentArgs{InvoiceID: inv.ID, PayCurrency: "xmr"})
+	require.NoError(err)
+	assert.Equal(inv.ID, ip.InvoiceID.String())
+	assert.Equal(1, srv.Requests("invoice-payment"))
+}
+
+func TestCredentials(t *testing.T) {
+	assert := assert.New(t)
+	newServer(t)
+
+	err := config.Load(&config.Credentials{
+		APIKey: "bad", IPNSecretKey: "ipn", Login: "l", Password: "p", Server: "http://x",
+	})
+	assert.NoError(err)
+
+	_, err = core.Status()
+	assert.EqualError(err, "code 403 (INVALID_API_KEY): Invalid api key")
+
+	_, err = payments.List(nil)
+	assert.Error(err)
+}
+
+func TestFaults(t *testing.T) {
+	assert := assert.New(t)
+	srv := newServer(t)
+
+	srv.Fail("status", nowpaymentstest.Fault{StatusCode: http.StatusServiceUnavailable, Code: "DOWN", Message: "maintenance", Times: 1})
+	_, err := core.Status()
+	assert.EqualError(err, "code 503 (DOWN): maintenance")
+	_, err = core.Status()
+	assert.NoError(err, "fault applies once")
+
+	srv.Fail("status", nowpaymentstest.Fault{Code: "DOWN", Times: 1})
+	_, err = core.Status()
+	assert.EqualError(err, "code 500 (DOWN): ")
+
+	srv.Fail("status", nowpaymentstest.Fault{Drop: true})
+	_, err = core.Status()
+	assert.Error(err)
+	srv.Reset()
+
+	srv.Delay("status", 20*time.Millisecond)
+	start := time.Now()
+	_, err = core.Status()
+	assert.NoError(err)
+	assert.GreaterOrEqual(time.Since(start), 20*time.Millisecond)
+}
+
+func TestCustody(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	srv := newServer(t)
+
+	alice, err := custody.NewUser(&custody.UserAccountArgs{Name: "alice"})
+	require.NoError(err)
+	bob, err := custody.NewUser(&custody.UserAccountArgs{Name: "bob"})
+	require.NoError(err)
+
+	_, err = custody.NewDepositFroMasterAccount(&custody.DepositArgs{Currency: "usdtbsc", Amount: 10, SubPartnerID: alice.ID})
+	require.NoError(err)
+
+	tr, err := custody.NewTransfer(&custody.TransferArgs{FromID: alice.ID, ToID: bob.ID, Amount: 4, Currency: "usdtbsc"})
+	require.NoError(err)
+	assert.Equal(custody.TransferFinished, tr.Status)
+
+	_, err = custody.NewTransfer(&custody.TransferArgs{FromID: bob.ID, ToID: alice.ID, Amount: 40, Currency: "usdtbsc"})
+	assert.Error(err, "insufficient balance")
+
+	_, err = custody.NewWriteOffToMaster(&custody.DepositArgs{Currency: "usdtbsc", Amount: 1, SubPartnerID: bob.ID})
+	require.NoError(err)
+
+	dp, err := custody.NewDepositWithPayment(&custody.DepositWithPaymentArgs{
+		DepositArgs: custody.DepositArgs{Currency: "usdtbsc", Amount: 5, SubPartnerID: bob.ID},
+	})
+	require.NoError(err)
+	require.NoError(srv.SetPaymentStatus(dp.ID.String(), "finished", 5))
+
+	b, err := custody.GetBalance(bob.ID)
+	require.NoError(err)
+	assert.Equal(8.0, b.Balances["usdtbsc"].Amount)
+
+	rep, err := custody.TreasuryReport(context.Background(), nil)
+	require.NoError(err)
+	assert.Len(rep.Users, 2)
+	assert.Equal(14.0, rep.Totals["usdtbsc"].Amount)
+
+	l, err := custody.NewLedger(bob.ID, &custody.LedgerOptions{MasterID: nowpaymentstest.MasterAccountID})
+	require.NoError(err)
+	r, err := l.Reconcile()
+	require.NoError(err)
+	assert.True(r.OK(), "%+v", r.Discrepancies)
+
+	ca := &custody.ConversionArgs{Amount: 2, FromCurrency: "usdtbsc", ToCurrency: "usddtrc20"}
+	cv, err := custody.NewConversion(ca)
+	require.NoError(err)
+	got, err := custody.GetConversion(cv.ID)
+	require.NoError(err)
+	assert.Equal(custody.ConversionFinished, got.Status)
+}
+
+func TestSubscriptions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	newServer(t)
+
+	pl, err := subscriptions.New(&subscriptions.SubscriptionArgs{Title: "monthly", IntervalDay: 30, Amount: 10, Currency: "usd"})
+	require.NoError(err)
+
+	pl, err = subscriptions.Update(pl.ID, &subscriptions.SubscriptionArgs{Amount: 12})
+	require.NoError(err)
+	assert.Equal(12.0, pl.Amount)
+
+	planID, err := strconv.ParseInt(pl.ID, 10, 64)
+	require.NoError(err)
+	rp, err := subscriptions.NewWithEmail(&subscriptions.EmailSubscriptionArgs{SubscriptionPlanID: planID, Email: "a@b.c"})
+	require.NoError(err)
+	assert.Equal("a@b.c", rp.Subscriber.Email)
+
+	rps, err := recurringPayment.List(nil)
+	require.NoError(err)
+	assert.Len(rps, 1)
+
+	_, err = recurringPayment.Delete(rp.ID)
+	require.NoError(err)
+	_, err = recurringPayment.Get(rp.ID)
+	assert.Error(err)
+}
@@ -0,0 +1,296 @@
+package nowpaymentstest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/ipn"
+)
+
+// Scenario is the way a simulated payment progresses through its statuses
+type Scenario string
+
+const (
+	// ScenarioSuccess: the payment is fully paid, confirmed and finished within minutes
+	ScenarioSuccess Scenario = "success"
+	// ScenarioPartiallyPaid: only half of the pay amount is received
+	ScenarioPartiallyPaid Scenario = "partially_paid"
+	// ScenarioFailed: the payment is received but fails during confirmation
+	ScenarioFailed Scenario = "failed"
+	// ScenarioExpired: nothing is received and the payment expires after 7 days
+	ScenarioExpired Scenario = "expired"
+	// ScenarioLateConfirmation: the payment is received but confirmed only after 2 hours
+	ScenarioLateConfirmation Scenario = "late_confirmation"
+)
+
+// Step is a status transition happening After the payment creation
+// Paid is the fraction of the pay amount received at this step
+type Step struct {
+	After  time.Duration
+	Status string
+	Paid   float64
+}
+
+var scenarios = map[Scenario][]Step{
+	ScenarioSuccess: {
+		{time.Minute, "confirming", 1},
+		{2 * time.Minute, "confirmed", 1},
+		{3 * time.Minute, "sending", 1},
+		{4 * time.Minute, "finished", 1},
+	},
+	ScenarioPartiallyPaid: {
+		{time.Minute, "partially_paid", 0.5},
+	},
+	ScenarioFailed: {
+		{time.Minute, "confirming", 1},
+		{2 * time.Minute, "failed", 1},
+	},
+	ScenarioExpired: {
+		{7 * 24 * time.Hour, "expired", 0},
+	},
+	ScenarioLateConfirmation: {
+		{time.Minute, "confirming", 1},
+		{2 * time.Hour, "confirmed", 1},
+		{2*time.Hour + time.Minute, "sending", 1},
+		{2*time.Hour + 2*time.Minute, "finished", 1},
+	},
+}
+
+// Steps returns the status transitions of a scenario
+func (sc Scenario) Steps() []Step {
+	return append([]Step{}, scenarios[sc]...)
+}
+
+// Clock is a manual clock, only moving forward when advanced
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a clock set at t
+func NewClock(t time.Time) *Clock {
+	return &Clock{now: t}
+}
+
+// Now returns the current time of the clock
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *Clock) advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+// Delivery is an IPN sent by the simulator
+type Delivery struct {
+	URL        string
+	PaymentID  string
+	Status     string
+	Body       []byte
+	Signature  string
+	StatusCode int
+	Err        error
+}
+
+// simulated is a payment driven by the simulator
+type simulated struct {
+	payment  *payment
+	scenario Scenario
+	start    time.Time
+	next     int
+}
+
+// Simulator drives the payments of a fake server through the statuses of a scenario, on a manual clock,
+// and POSTs signed IPNs to their ipn_callback_url at each transition
+//
+// The scenario of a payment is taken from PaymentArgs.Case, Default is used when the case is empty or unknown
+type Simulator struct {
+	// Default is the scenario of payments created without a known case
+	Default Scenario
+	// Secret is the IPN secret key used to sign notifications
+	Secret string
+	// Client is used to send the IPNs
+	Client *http.Client
+
+	srv   *Server
+	clock *Clock
+
+	mu         sync.Mutex
+	payments   []*simulated
+	deliveries []Delivery
+}
+
+// NewSimulator attaches a simulator to the server, replacing its clock
+// Only payments created after this call are driven by the simulator
+func NewSimulator(srv *Server, clock *Clock) *Simulator {
+	sim := &Simulator{
+		Default: ScenarioSuccess,
+		Secret:  IPNSecretKey,
+		Client:  &http.Client{Timeout: 5 * time.Second},
+		srv:     srv,
+		clock:   clock,
+	}
+
+	srv.mu.Lock()
+	srv.now = clock.Now
+	srv.created = sim.track
+	srv.mu.Unlock()
+
+	return sim
+}
+
+// Clock returns the clock of the simulator
+func (sim *Simulator) Clock() *Clock {
+	return sim.clock
+}
+
+// track registers a payment created on the server, the server lock is held
+func (sim *Simulator) track(p *payment) {
+	sc := Scenario(p.Case)
+	if _, ok := scenarios[sc]; !ok {
+		sc = sim.Default
+	}
+
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	sim.payments = append(sim.payments, &simulated{payment: p, scenario: sc, start: p.CreatedAt})
+}
+
+// Drive changes the scenario of a payment, restarting it from the current time
+func (sim *Simulator) Drive(paymentID string, sc Scenario) error {
+	if _, ok := scenarios[sc]; !ok {
+		return fmt.Errorf("unknown scenario %q", sc)
+	}
+
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	for _, sp := range sim.payments {
+		if strconv.FormatInt(sp.payment.ID, 10) == paymentID {
+			sp.scenario, sp.start, sp.next = sc, sim.clock.Now(), 0
+			return nil
+		}
+	}
+	return fmt.Errorf("payment %s not found", paymentID)
+}
+
+// transition is a step due for a payment
+type transition struct {
+	sp   *simulated
+	step Step
+	at   time.Time
+}
+
+// Advance moves the clock forward, applying the due status transitions in chronological order and
+// sending the related IPNs. The first delivery error is returned, all deliveries are recorded
+func (sim *Simulator) Advance(d time.Duration) error {
+	now := sim.clock.advance(d)
+
+	sim.mu.Lock()
+	var due []transition
+	for _, sp := range sim.payments {
+		steps := scenarios[sp.scenario]
+		for ; sp.next < len(steps); sp.next++ {
+			at := sp.start.Add(steps[sp.next].After)
+			if at.After(now) {
+				break
+			}
+			due = append(due, transition{sp, steps[sp.next], at})
+		}
+	}
+	sim.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+
+	var first error
+	for _, t := range due {
+		sim.srv.mu.Lock()
+		p := t.sp.payment
+		sim.srv.setPaymentStatus(p, t.step.Status, p.PayAmount*t.step.Paid)
+		p.UpdatedAt = t.at
+		url := p.CallbackURL
+		body := notification(p)
+		sim.srv.mu.Unlock()
+
+		if url == "" {
+			continue
+		}
+
+		dl := sim.send(url, body)
+		if dl.Err != nil && first == nil {
+			first = dl.Err
+		}
+	}
+
+	return first
+}
+
+// Deliveries returns the IPNs sent so far
+func (sim *Simulator) Deliveries() []Delivery {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	return append([]Delivery{}, sim.deliveries...)
+}
+
+func (sim *Simulator) send(url string, n *ipn.IPNPaymentStatus) Delivery {
+	dl := Delivery{
+		URL:       url,
+		PaymentID: n.PaymentID.String(),
+		Status:    n.PaymentStatus,
+	}
+
+	dl.Body, dl.Err = json.Marshal(n)
+	if dl.Err == nil {
+		dl.Signature, dl.Err = ipn.Sign(sim.Secret, dl.Body)
+	}
+	if dl.Err == nil {
+		s := &ipn.Sender{Secret: sim.Secret, Client: sim.Client}
+		dl.StatusCode, dl.Err = s.Send(context.Background(), url, dl.Body)
+	}
+
+	sim.mu.Lock()
+	sim.deliveries = append(sim.deliveries, dl)
+	sim.mu.Unlock()
+
+	return dl
+}
+
+// notification returns the IPN body of a payment, the server lock must be held
+func notification(p *payment) *ipn.IPNPaymentStatus {
+	n := &ipn.IPNPaymentStatus{
+		ActuallyPaid:     core.Float(p.ActuallyPaid),
+		Fee:              ipn.IPNPaymentFees{Currency: p.PayCurrency},
+		OrderDescription: p.OrderDescription,
+		OrderID:          p.OrderID,
+		OutcomeAmount:    core.Float(p.OutcomeAmount),
+		OutcomeCurrency:  p.OutcomeCurrency,
+		PayAddress:       p.PayAddress,
+		PayAmount:        core.Float(p.PayAmount),
+		PayCurrency:      p.PayCurrency,
+		PaymentID:        core.ID(strconv.FormatInt(p.ID, 10)),
+		PaymentStatus:    p.Status,
+		PriceAmount:      core.Float(p.PriceAmount),
+		PriceCurrency:    p.PriceCurrency,
+		PurchaseID:       core.ID(strconv.FormatInt(p.PurchaseID, 10)),
+	}
+	if p.InvoiceID != 0 {
+		n.InvoiceID = core.ID(strconv.FormatInt(p.InvoiceID, 10))
+	}
+	return n
+}
@@ -0,0 +1,99 @@
+package nowpaymentstest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/ipn"
+	"github.com/CIDgravity/go-nowpayments/nowpaymentstest"
+	"github.com/CIDgravity/go-nowpayments/payments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulator(t *testing.T) {
+	tests := []struct {
+		scenario nowpaymentstest.Scenario
+		advance  []time.Duration
+		want     []string
+	}{
+		{nowpaymentstest.ScenarioSuccess, []time.Duration{10 * time.Minute}, []string{"confirming", "confirmed", "sending", "finished"}},
+		{nowpaymentstest.ScenarioPartiallyPaid, []time.Duration{time.Hour}, []string{"partially_paid"}},
+		{nowpaymentstest.ScenarioFailed, []time.Duration{time.Hour}, []string{"confirming", "failed"}},
+		{nowpaymentstest.ScenarioExpired, []time.Duration{24 * time.Hour, 7 * 24 * time.Hour}, []string{"expired"}},
+		{nowpaymentstest.ScenarioLateConfirmation, []time.Duration{time.Hour, 2 * time.Hour}, []string{"confirming", "confirmed", "sending", "finished"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.scenario), func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+			srv := newServer(t)
+			sim := nowpaymentstest.NewSimulator(srv, nowpaymentstest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+			var mu sync.Mutex
+			var got []string
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := ipn.IPNPaymentStatus{}
+				if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if err := ipn.VerifyRequestSignature(r.Header.Get("x-nowpayments-sig"), n); err != nil {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				mu.Lock()
+				got = append(got, n.PaymentStatus)
+				mu.Unlock()
+			}))
+			defer receiver.Close()
+
+			p, err := payments.New(&payments.PaymentArgs{
+				PaymentAmount: payments.PaymentAmount{
+					PriceAmount: 100, PriceCurrency: "usd", PayCurrency: "btc", OrderID: "order-1", CallbackURL: receiver.URL,
+				},
+				Case: string(tt.scenario),
+			})
+			require.NoError(err)
+
+			for _, d := range tt.advance {
+				require.NoError(sim.Advance(d))
+			}
+			assert.Equal(tt.want, got)
+			assert.Len(sim.Deliveries(), len(tt.want))
+			body := string(sim.Deliveries()[0].Body)
+			assert.Contains(body, `"payment_id":`+p.ID.String(), "IDs sent as numbers")
+			assert.Contains(body, `"invoice_id":null`)
+
+			s, err := payments.Status(p.ID.String())
+			require.NoError(err)
+			assert.Equal(tt.want[len(tt.want)-1], s.Status)
+		})
+	}
+}
+
+func TestSimulatorDrive(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	srv := newServer(t)
+	sim := nowpaymentstest.NewSimulator(srv, nowpaymentstest.NewClock(time.Now()))
+
+	p, err := payments.New(&payments.PaymentArgs{
+		PaymentAmount: payments.PaymentAmount{PriceAmount: 100, PriceCurrency: "usd", PayCurrency: "btc"},
+	})
+	require.NoError(err)
+
+	require.NoError(sim.Drive(p.ID.String(), nowpaymentstest.ScenarioFailed))
+	assert.Error(sim.Drive(p.ID.String(), "unknown"))
+	assert.Error(sim.Drive("0", nowpaymentstest.ScenarioFailed))
+
+	require.NoError(sim.Advance(time.Hour))
+	s, err := payments.Status(p.ID.String())
+	require.NoError(err)
+	assert.Equal("failed", s.Status)
+	assert.Empty(sim.Deliveries(), "no IPN without callback URL")
+}
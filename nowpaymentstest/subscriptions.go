@@ -0,0 +1,211 @@
+package nowpaymentstest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type plan struct {
+	ID               string    `json:"id"`
+	Title            string    `json:"title"`
+	IntervalDay      string    `json:"interval_day"`
+	IpnCallbackURL   string    `json:"ipn_callback_url,omitempty"`
+	SuccessURL       string    `json:"success_url,omitempty"`
+	CancelURL        string    `json:"cancel_url,omitempty"`
+	PartiallyPaidURL string    `json:"partially_paid_url,omitempty"`
+	Amount           float64   `json:"amount"`
+	Currency         string    `json:"currency"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type subscriber struct {
+	Email        string `json:"email,omitempty"`
+	SubPartnerID string `json:"sub_partner_id,omitempty"`
+}
+
+type recurring struct {
+	ID                 string     `json:"id"`
+	SubscriptionPlanID string     `json:"subscription_plan_id"`
+	IsActive           bool       `json:"is_active"`
+	Status             string     `json:"status"`
+	ExpireDate         string     `json:"expire_date"`
+	Subscriber         subscriber `json:"subscriber"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// planArgs are the arguments of the plan creation and update routes
+type planArgs struct {
+	Title       string  `json:"title"`
+	IntervalDay int64   `json:"interval_day"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+}
+
+func (s *Server) plan(id string) *plan {
+	for _, p := range s.plans {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *Server) createPlan(w http.ResponseWriter, r *request) {
+	a := &planArgs{}
+	if err := r.decode(a); err != nil || a.Title == "" || a.IntervalDay <= 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", "title and interval_day are required")
+		return
+	}
+
+	now := s.now()
+	p := &plan{
+		ID:          strconv.FormatInt(s.id(), 10),
+		Title:       a.Title,
+		IntervalDay: strconv.FormatInt(a.IntervalDay, 10),
+		Amount:      a.Amount,
+		Currency:    a.Currency,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.plans = append(s.plans, p)
+
+	writeResult(w, http.StatusCreated, p)
+}
+
+func (s *Server) updatePlan(w http.ResponseWriter, r *request) {
+	p := s.plan(r.id)
+	if p == nil {
+		writeError(w, http.StatusNotFound, "PLAN_NOT_FOUND", "Subscription plan not found")
+		return
+	}
+
+	a := &planArgs{}
+	if err := r.decode(a); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	if a.Title != "" {
+		p.Title = a.Title
+	}
+	if a.IntervalDay > 0 {
+		p.IntervalDay = strconv.FormatInt(a.IntervalDay, 10)
+	}
+	if a.Amount > 0 {
+		p.Amount = a.Amount
+	}
+	if a.Currency != "" {
+		p.Currency = a.Currency
+	}
+	p.UpdatedAt = s.now()
+
+	writeResult(w, http.StatusOK, p)
+}
+
+func (s *Server) getPlan(w http.ResponseWriter, r *request) {
+	p := s.plan(r.id)
+	if p == nil {
+		writeError(w, http.StatusNotFound, "PLAN_NOT_FOUND", "Subscription plan not found")
+		return
+	}
+
+	writeResult(w, http.StatusOK, p)
+}
+
+func (s *Server) listPlans(w http.ResponseWriter, r *request) {
+	limit, off := offset(r)
+	writeResult(w, http.StatusOK, append([]*plan{}, paginate(s.plans, limit, off)...))
+}
+
+func (s *Server) createRecurring(w http.ResponseWriter, r *request) {
+	var a struct {
+		SubscriptionPlanID int64  `json:"subscription_plan_id"`
+		Email              string `json:"email"`
+		SubPartnerID       int64  `json:"sub_partner_id"`
+	}
+	if err := r.decode(&a); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+
+	p := s.plan(strconv.FormatInt(a.SubscriptionPlanID, 10))
+	if p == nil {
+		writeError(w, http.StatusNotFound, "PLAN_NOT_FOUND", "Subscription plan not found")
+		return
+	}
+
+	sub := subscriber{Email: a.Email}
+	if a.SubPartnerID != 0 {
+		sub.SubPartnerID = strconv.FormatInt(a.SubPartnerID, 10)
+		if s.user(sub.SubPartnerID) == nil {
+			writeError(w, http.StatusNotFound, "SUB_PARTNER_NOT_FOUND", "Sub partner not found")
+			return
+		}
+	}
+	if sub.Email == "" && sub.SubPartnerID == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", "email or sub_partner_id is required")
+		return
+	}
+
+	days, _ := strconv.Atoi(p.IntervalDay)
+	now := s.now()
+	rp := &recurring{
+		ID:                 strconv.FormatInt(s.id(), 10),
+		SubscriptionPlanID: p.ID,
+		IsActive:           false,
+		Status:             "WAITING_PAY",
+		ExpireDate:         now.AddDate(0, 0, days).UTC().Format(timeLayout),
+		Subscriber:         sub,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	s.recurring = append(s.recurring, rp)
+
+	writeResult(w, http.StatusCreated, []*recurring{rp})
+}
+
+func (s *Server) getRecurring(w http.ResponseWriter, r *request) {
+	for _, rp := range s.recurring {
+		if rp.ID == r.id {
+			writeResult(w, http.StatusOK, rp)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "SUBSCRIPTION_NOT_FOUND", "Subscription not found")
+}
+
+func (s *Server) listRecurring(w http.ResponseWriter, r *request) {
+	q := r.URL.Query()
+	rps := []*recurring{}
+	for _, rp := range s.recurring {
+		if v := q.Get("is_active"); v != "" && v != strconv.FormatBool(rp.IsActive) {
+			continue
+		}
+		if v := q.Get("status"); v != "" && v != rp.Status {
+			continue
+		}
+		if v := q.Get("subscription_plan_id"); v != "" && v != rp.SubscriptionPlanID {
+			continue
+		}
+		rps = append(rps, rp)
+	}
+
+	limit, off := offset(r)
+	writeResult(w, http.StatusOK, append([]*recurring{}, paginate(rps, limit, off)...))
+}
+
+func (s *Server) deleteRecurring(w http.ResponseWriter, r *request) {
+	for i, rp := range s.recurring {
+		if rp.ID == r.id {
+			s.recurring = append(s.recurring[:i], s.recurring[i+1:]...)
+			writeResult(w, http.StatusOK, "success")
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "SUBSCRIPTION_NOT_FOUND", "Subscription not found")
+}
@@ -0,0 +1,260 @@
+package payments
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/rotisserie/eris"
+)
+
+const idempotencyPageSize = 100
+
+// IntentState is the state of a payment creation intent
+type IntentState string
+
+const (
+	// IntentPending means the payment creation has been sent but its outcome is unknown
+	IntentPending IntentState = "pending"
+	// IntentCreated means the payment has been created
+	IntentCreated IntentState = "created"
+)
+
+// Intent records a payment creation for an order ID
+type Intent struct {
+	OrderID   string      `json:"order_id"`
+	PaymentID string      `json:"payment_id,omitempty"`
+	State     IntentState `json:"state"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// IntentStore persists payment creation intents
+type IntentStore interface {
+	// Get returns the intent recorded for the order ID, or nil if there is none
+	Get(orderID string) (*Intent, error)
+	// Put records or replaces the intent of an order ID
+	Put(in *Intent) error
+	// Delete removes the intent of an order ID
+	Delete(orderID string) error
+}
+
+// MemoryIntentStore is an IntentStore keeping intents in memory
+type MemoryIntentStore struct {
+	mu      sync.Mutex
+	intents map[string]Intent
+}
+
+// NewMemoryIntentStore returns an empty in-memory intent store
+func NewMemoryIntentStore() *MemoryIntentStore {
+	return &MemoryIntentStore{intents: make(map[string]Intent)}
+}
+
+// Get returns the intent recorded for the order ID, or nil if there is none
+func (s *MemoryIntentStore) Get(orderID string) (*Intent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	in, ok := s.intents[orderID]
+	if !ok {
+		return nil, nil
+	}
+	return &in, nil
+}
+
+// Put records or replaces the intent of an order ID
+func (s *MemoryIntentStore) Put(in *Intent) error {
+	if in == nil {
+		return errors.New("nil intent")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.intents[in.OrderID] = *in
+	return nil
+}
+
+// Delete removes the intent of an order ID
+func (s *MemoryIntentStore) Delete(orderID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.intents, orderID)
+	return nil
+}
+
+// Idempotent creates payments keyed by their order ID, so that an order gets only one active payment
+// The intent is recorded before sending the request. When the outcome is unknown (network error, timeout
+// or server error), the payment is looked up by order ID in the list of payments before retrying
+type Idempotent struct {
+	store IntentStore
+
+	mu    sync.Mutex
+	locks map[string]*orderLock
+}
+
+// orderLock is the lock of an order ID, along with the number of calls holding or waiting for it
+type orderLock struct {
+	sync.Mutex
+	n int
+}
+
+// NewIdempotent returns an idempotent payment creator using s to record intents
+func NewIdempotent(s IntentStore) *Idempotent {
+	return &Idempotent{
+		store: s,
+		locks: make(map[string]*orderLock),
+	}
+}
+
+// New creates a payment for pa.OrderID, unless an active payment already exists for this order ID
+// JWT is required for this request (to look up payments)
+func (i *Idempotent) New(pa *PaymentArgs) (*Payment, error) {
+	if pa == nil {
+		return nil, errors.New("nil payment args")
+	}
+	if pa.OrderID == "" {
+		return nil, eris.New("idempotent payment: empty order ID")
+	}
+
+	unlock := i.lock(pa.OrderID)
+	defer unlock()
+
+	in, err := i.store.Get(pa.OrderID)
+	if err != nil {
+		return nil, eris.Wrap(err, "idempotent payment: get intent")
+	}
+
+	if in != nil {
+		p, err := findByOrderID(pa.OrderID, in.PaymentID, in.CreatedAt)
+		if err != nil {
+			return nil, eris.Wrap(err, "idempotent payment: lookup")
+		}
+		switch {
+		case p != nil && isActive(p.Status):
+			return p, i.created(in, p)
+		case p != nil:
+			// The recorded payment is over (failed, expired or refunded), a new one is created for the order
+			in = nil
+		case in.State == IntentCreated:
+			return nil, eris.Errorf("idempotent payment: payment %s recorded for order %q but not found", in.PaymentID, pa.OrderID)
+		}
+	}
+
+	if in == nil {
+		in = &Intent{OrderID: pa.OrderID, State: IntentPending, CreatedAt: time.Now()}
+		if err := i.store.Put(in); err != nil {
+			return nil, eris.Wrap(err, "idempotent payment: put intent")
+		}
+	}
+
+	p, err := New(pa)
+	if err == nil {
+		return p, i.created(in, p)
+	}
+
+	if !isAmbiguous(err) {
+		if derr := i.store.Delete(pa.OrderID); derr != nil {
+			return nil, eris.Wrap(derr, "idempotent payment: delete intent")
+		}
+		return nil, err
+	}
+
+	// The payment may have been created anyway
+	found, lerr := findByOrderID(pa.OrderID, "", in.CreatedAt)
+	if lerr != nil || found == nil {
+		// Intent is kept pending, the next call will look the payment up again
+		return nil, err
+	}
+
+	return found, i.created(in, found)
+}
+
+func (i *Idempotent) created(in *Intent, p *Payment) error {
+	in.State = IntentCreated
+	in.PaymentID = p.ID.String()
+	return eris.Wrap(i.store.Put(in), "idempotent payment: put intent")
+}
+
+// lock serializes payment creations for the same order ID
+// The lock is dropped from the map once no call holds or waits for it anymore
+func (i *Idempotent) lock(orderID string) func() {
+	i.mu.Lock()
+	l, ok := i.locks[orderID]
+	if !ok {
+		l = &orderLock{}
+		i.locks[orderID] = l
+	}
+	l.n++
+	i.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		i.mu.Lock()
+		l.n--
+		if l.n == 0 {
+			delete(i.locks, orderID)
+		}
+		i.mu.Unlock()
+	}
+}
+
+// isAmbiguous tells if the payment may have been created despite err
+func isAmbiguous(err error) bool {
+	var ae *core.APIError
+	if errors.As(err, &ae) {
+		return ae.Temporary()
+	}
+	return true
+}
+
+// isActive tells if a payment can still be paid or has been paid
+func isActive(status string) bool {
+	switch status {
+	case "failed", "expired", "refunded":
+		return false
+	}
+	return true
+}
+
+// findByOrderID looks up the active payment of an order ID created since the supplied date
+// When there is none, the payment identified by paymentID is returned if it is listed, whatever its status
+func findByOrderID(orderID, paymentID string, since time.Time) (*Payment, error) {
+	o := &ListOption{
+		Limit:   idempotencyPageSize,
+		SortBy:  "created_at",
+		OrderBy: "desc",
+	}
+	if !since.IsZero() {
+		// Only the day is taken into account by the API
+		o.DateFrom = since.AddDate(0, 0, -1)
+	}
+
+	var recorded *Payment
+	for {
+		ps, err := List(o)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range ps {
+			if p.OrderID != orderID {
+				continue
+			}
+			if isActive(p.Status) {
+				return p, nil
+			}
+			if paymentID != "" && p.ID.String() == paymentID {
+				recorded = p
+			}
+		}
+
+		if len(ps) < idempotencyPageSize {
+			return recorded, nil
+		}
+		o.Page++
+	}
+}
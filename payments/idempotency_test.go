@@ -0,0 +1,151 @@
+package payments
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIdempotentNew(t *testing.T) {
+	assert := assert.New(t)
+
+	// api mocks the API: create is the outcome of the payment creation and list the payments list body
+	api := func(create func() (*http.Response, error), list string) func(*mocks.HTTPClient) {
+		return func(c *mocks.HTTPClient) {
+			c.EXPECT().Do(mock.Anything).Call.Return(
+				func(req *http.Request) *http.Response {
+					switch {
+					case req.URL.Path == "/v1/auth":
+						return newResponseOK(`{"token":"tok"}`)
+					case req.URL.Path == "/v1/payment/":
+						return newResponseOK(list)
+					case req.URL.Path == "/v1/payment" && req.Method == http.MethodPost:
+						r, _ := create()
+						return r
+					}
+					return nil
+				},
+				func(req *http.Request) error {
+					if req.URL.Path == "/v1/payment" && req.Method == http.MethodPost {
+						_, err := create()
+						return err
+					}
+					return nil
+				})
+		}
+	}
+	created := func() (*http.Response, error) {
+		return newResponseOK(`{"payment_id":"1234","order_id":"O1"}`), nil
+	}
+	timeout := func() (*http.Response, error) {
+		return nil, errors.New("timeout")
+	}
+	rejected := func() (*http.Response, error) {
+		return newResponse(http.StatusBadRequest, `{"statusCode":400,"code":"INVALID_REQUEST_PARAMS","message":"bad"}`), nil
+	}
+
+	tests := []struct {
+		name    string
+		intent  *Intent
+		init    func(*mocks.HTTPClient)
+		wantErr bool
+		after   func(*Payment, *Intent)
+	}{
+		{"new order", nil, api(created, `{"data":[]}`), false,
+			func(p *Payment, in *Intent) {
+				assert.Equal(core.ID("1234"), p.ID)
+				assert.Equal(IntentCreated, in.State)
+				assert.Equal("1234", in.PaymentID)
+			},
+		},
+		{"ambiguous failure, payment found", nil,
+			api(timeout, `{"data":[{"payment_id":99,"order_id":"other"},{"payment_id":1234,"order_id":"O1","payment_status":"waiting"}]}`), false,
+			func(p *Payment, in *Intent) {
+				assert.Equal(core.ID("1234"), p.ID)
+				assert.Equal(IntentCreated, in.State)
+			},
+		},
+		{"ambiguous failure, payment not found", nil, api(timeout, `{"data":[]}`), true,
+			func(p *Payment, in *Intent) {
+				assert.Nil(p)
+				if assert.NotNil(in) {
+					assert.Equal(IntentPending, in.State)
+				}
+			},
+		},
+		{"pending intent, payment not found", &Intent{OrderID: "O1", State: IntentPending}, api(created, `{"data":[]}`), false,
+			func(p *Payment, in *Intent) {
+				assert.Equal(core.ID("1234"), p.ID)
+				assert.Equal(IntentCreated, in.State)
+			},
+		},
+		{"expired payment is not reused", &Intent{OrderID: "O1", State: IntentPending},
+			api(created, `{"data":[{"payment_id":77,"order_id":"O1","payment_status":"expired"}]}`), false,
+			func(p *Payment, in *Intent) {
+				assert.Equal(core.ID("1234"), p.ID)
+			},
+		},
+		{"existing payment", &Intent{OrderID: "O1", State: IntentCreated, PaymentID: "1234"},
+			api(func() (*http.Response, error) {
+				t.Fatal("payment must not be created twice")
+				return nil, nil
+			}, `{"data":[{"payment_id":1234,"order_id":"O1","payment_status":"finished"}]}`), false,
+			func(p *Payment, in *Intent) {
+				assert.Equal(core.ID("1234"), p.ID)
+			},
+		},
+		{"recorded payment expired", &Intent{OrderID: "O1", State: IntentCreated, PaymentID: "77"},
+			api(created, `{"data":[{"payment_id":77,"order_id":"O1","payment_status":"expired"}]}`), false,
+			func(p *Payment, in *Intent) {
+				assert.Equal(core.ID("1234"), p.ID)
+				assert.Equal(IntentCreated, in.State)
+				assert.Equal("1234", in.PaymentID)
+			},
+		},
+		{"recorded payment not found", &Intent{OrderID: "O1", State: IntentCreated, PaymentID: "77"},
+			api(func() (*http.Response, error) {
+				t.Fatal("payment must not be created")
+				return nil, nil
+			}, `{"data":[]}`), true,
+			func(p *Payment, in *Intent) {
+				assert.Nil(p)
+				assert.Equal("77", in.PaymentID)
+			},
+		},
+		{"rejected payment", nil, api(rejected, `{"data":[]}`), true,
+			func(p *Payment, in *Intent) {
+				assert.Nil(p)
+				assert.Nil(in)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mocks.NewHTTPClient(t)
+			core.UseClient(c)
+			if tt.init != nil {
+				tt.init(c)
+			}
+			s := NewMemoryIntentStore()
+			if tt.intent != nil {
+				assert.NoError(s.Put(tt.intent))
+			}
+			ip := NewIdempotent(s)
+			got, err := ip.New(&PaymentArgs{PaymentAmount: PaymentAmount{OrderID: "O1"}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Idempotent.New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Empty(ip.locks)
+			in, err := s.Get("O1")
+			assert.NoError(err)
+			if tt.after != nil {
+				tt.after(got, in)
+			}
+		})
+	}
+}
@@ -22,18 +22,38 @@ type InvoiceArgs struct {
 type Invoice struct {
 	InvoiceArgs
 
-	PriceAmount      string  `json:"price_amount"`
-	ID               string  `json:"id"`
-	CreatedAt        string  `json:"created_at,omitempty"`
-	InvoiceURL       string  `json:"invoice_url,omitempty"`
-	UpdatedAt        string  `json:"updated_at,omitempty"`
-	TokenID          string  `json:"token_id,omitempty"`
-	IsFixedRate      bool    `json:"is_fixed_rate,omitempty"`
-	IsFeePaidByUser  bool    `json:"is_fee_paid_by_user,omitempty"`
-	PayCurrency      *string `json:"pay_currency,omitempty"`
-	IpnCallbackURL   string  `json:"ipn_callback_url,omitempty"`
-	PartiallyPaidURL *string `json:"partially_paid_url,omitempty"`
-	PayoutCurrency   *string `json:"payout_currency,omitempty"`
+	PriceAmount      string    `json:"price_amount"`
+	ID               string    `json:"id"`
+	CreatedAt        core.Time `json:"created_at,omitempty"`
+	InvoiceURL       string    `json:"invoice_url,omitempty"`
+	UpdatedAt        core.Time `json:"updated_at,omitempty"`
+	TokenID          string    `json:"token_id,omitempty"`
+	IsFixedRate      bool      `json:"is_fixed_rate,omitempty"`
+	IsFeePaidByUser  bool      `json:"is_fee_paid_by_user,omitempty"`
+	PayCurrency      *string   `json:"pay_currency,omitempty"`
+	IpnCallbackURL   string    `json:"ipn_callback_url,omitempty"`
+	PartiallyPaidURL *string   `json:"partially_paid_url,omitempty"`
+	PayoutCurrency   *string   `json:"payout_currency,omitempty"`
+
+	// Extra holds the fields unknown to the library, encoded along with the others
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps the unknown fields in Extra
+func (i *Invoice) UnmarshalJSON(data []byte) error {
+	type invoice Invoice
+	extra, err := core.DecodeExtra(data, (*invoice)(i))
+	if err != nil {
+		return err
+	}
+	i.Extra = extra
+	return nil
+}
+
+// MarshalJSON encodes Extra along with the known fields
+func (i Invoice) MarshalJSON() ([]byte, error) {
+	type invoice Invoice
+	return core.EncodeExtra(invoice(i), i.Extra)
 }
 
 // NewInvoice creates an invoice
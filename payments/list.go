@@ -3,16 +3,18 @@ package payments
 import (
 	"fmt"
 	"net/url"
+	"time"
 
-	"github.com/CIDgravity/go-nowpayments/config"
 	"github.com/CIDgravity/go-nowpayments/core"
 	"github.com/rotisserie/eris"
 )
 
 // ListOption are options applying to the list of transactions
 type ListOption struct {
-	DateFrom string
-	DateTo   string
+	// DateFrom and DateTo filter on the creation day, the calendar date of the time in its own location being
+	// sent, e.g. 2026-03-31 for 2026-03-31T00:00:00+02:00, zero meaning no filter
+	DateFrom time.Time
+	DateTo   time.Time
 	Limit    int
 	OrderBy  string
 	Page     int
@@ -21,18 +23,18 @@ type ListOption struct {
 
 // List returns a list of all transactions, depending on the supplied options (which can be nil)
 // JWT is required for this request
-func List(o *ListOption) ([]*Payment[int64], error) {
+func List(o *ListOption) ([]*Payment, error) {
 	u := url.Values{}
 
 	if o != nil {
 		if o.Limit != 0 {
 			u.Set("limit", fmt.Sprintf("%d", o.Limit))
 		}
-		if o.DateFrom != "" {
-			u.Set("dateFrom", o.DateFrom)
+		if !o.DateFrom.IsZero() {
+			u.Set("dateFrom", o.DateFrom.Format(core.DateLayout))
 		}
-		if o.DateTo != "" {
-			u.Set("dateTo", o.DateTo)
+		if !o.DateTo.IsZero() {
+			u.Set("dateTo", o.DateTo.Format(core.DateLayout))
 		}
 		u.Set("page", fmt.Sprintf("%d", o.Page))
 		if o.SortBy != "" {
@@ -43,19 +45,15 @@ func List(o *ListOption) ([]*Payment[int64], error) {
 		}
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "list")
 	}
 
-	type plist struct {
-		Data []*Payment[int64] `json:"data"`
-	}
-
-	pl := &plist{Data: make([]*Payment[int64], 0)}
+	pl := make([]*Payment, 0)
 	par := &core.SendParams{
 		RouteName: "payments-list",
-		Into:      pl,
+		Into:      &pl,
 		Values:    u,
 		JWTToken:  tok,
 	}
@@ -65,5 +63,5 @@ func List(o *ListOption) ([]*Payment[int64], error) {
 		return nil, err
 	}
 
-	return pl.Data, nil
+	return pl, nil
 }
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/CIDgravity/go-nowpayments/core"
 	"github.com/CIDgravity/go-nowpayments/mocks"
@@ -18,7 +19,7 @@ func TestList(t *testing.T) {
 		name  string
 		o     *ListOption
 		init  func(*mocks.HTTPClient)
-		after func([]*Payment[int64], error)
+		after func([]*Payment, error)
 	}{
 		{"route and response", nil,
 			func(c *mocks.HTTPClient) {
@@ -35,10 +36,10 @@ func TestList(t *testing.T) {
 						return nil
 					}, nil)
 			},
-			func(ps []*Payment[int64], err error) {
+			func(ps []*Payment, err error) {
 				assert.NoError(err)
 				if assert.Len(ps, 1) {
-					assert.Equal(int64(1), ps[0].ID)
+					assert.Equal(core.ID("1"), ps[0].ID)
 				}
 			}},
 		{"payment_id as a string", nil,
@@ -56,10 +57,10 @@ func TestList(t *testing.T) {
 						return nil
 					}, nil)
 			},
-			func(ps []*Payment[int64], err error) {
+			func(ps []*Payment, err error) {
 				assert.NoError(err)
 				if assert.Len(ps, 1) {
-					assert.Equal(int64(54321), ps[0].ID)
+					assert.Equal(core.ID("54321"), ps[0].ID)
 				}
 			}},
 		{"api error", nil,
@@ -85,7 +86,7 @@ func TestList(t *testing.T) {
 			func(c *mocks.HTTPClient) {
 				c.EXPECT().Do(mock.Anything).Return(nil, errors.New("bad credentials"))
 			},
-			func(ps []*Payment[int64], err error) {
+			func(ps []*Payment, err error) {
 				assert.Nil(ps)
 				assert.Error(err)
 				assert.Equal("list: auth: bad credentials", err.Error())
@@ -93,8 +94,8 @@ func TestList(t *testing.T) {
 		},
 		{"with all options", &ListOption{
 			Limit:    2,
-			DateFrom: "2020-01-01",
-			DateTo:   "2022-01-01",
+			DateFrom: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			DateTo:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
 			OrderBy:  "asc",
 			SortBy:   "created_at",
 			Page:     3,
@@ -109,6 +110,20 @@ func TestList(t *testing.T) {
 			},
 			nil,
 		},
+		{"calendar dates of the caller sent", &ListOption{
+			DateFrom: time.Date(2026, 3, 1, 23, 0, 0, 0, time.FixedZone("UTC-2", -2*3600)),
+			DateTo:   time.Date(2026, 3, 31, 0, 0, 0, 0, time.FixedZone("UTC+2", 2*3600)),
+		},
+			func(c *mocks.HTTPClient) {
+				resp := newResponseOK(`{"data":[{"payment_id":1}]}`)
+				c.EXPECT().Do(mock.Anything).Run(func(r *http.Request) {
+					if strings.HasPrefix(r.URL.Path, "/v1/payment") {
+						assert.Equal("dateFrom=2026-03-01&dateTo=2026-03-31&page=0", r.URL.RawQuery)
+					}
+				}).Return(resp, nil)
+			},
+			nil,
+		},
 		{"with empty options", &ListOption{},
 			func(c *mocks.HTTPClient) {
 				resp := newResponseOK(`{"data":[{"payment_id":1}]}`)
@@ -47,43 +47,81 @@ type PaymentArgs struct {
 	Case string `json:"case,omitempty"`
 }
 
+// PaymentFee holds the fees charged on a payment, in Currency
+type PaymentFee struct {
+	Currency      string     `json:"currency"`
+	DepositFee    core.Float `json:"depositFee"`
+	ServiceFee    core.Float `json:"serviceFee"`
+	WithdrawalFee core.Float `json:"withdrawalFee"`
+}
+
+// Total returns the sum of the fees
+func (f *PaymentFee) Total() float64 {
+	if f == nil {
+		return 0
+	}
+	return f.DepositFee.Float64() + f.ServiceFee.Float64() + f.WithdrawalFee.Float64()
+}
+
 // Payment holds payment related information once we get a response
 // This struct will be used in multiple API calls
-// Inconsistency on their side: we need to use generics, because some times payment_id is string or int64
-type Payment[T string | int64] struct {
+// Inconsistency on their side: IDs and amounts are sometimes strings, sometimes numbers, so flexible types are used
+type Payment struct {
 	PaymentAmount
 
-	ID           T           `json:"payment_id"`
-	InvoiceID    json.Number `json:"invoice_id"`
-	Status       string      `json:"payment_status"`
-	PayAddress   string      `json:"pay_address"`
-	PayinExtraID string      `json:"payin_extra_id"`
-	PayAmount    float64     `json:"pay_amount"`
-	ActuallyPaid float64     `json:"actually_paid"`
-	PayCurrency  string      `json:"pay_currency"`
-	PurchaseID   json.Number `json:"purchase_id"`
+	ID           core.ID    `json:"payment_id"`
+	InvoiceID    core.ID    `json:"invoice_id"`
+	Status       string     `json:"payment_status"`
+	PayAddress   string     `json:"pay_address"`
+	PayinExtraID string     `json:"payin_extra_id"`
+	PayAmount    core.Float `json:"pay_amount"`
+	ActuallyPaid core.Float `json:"actually_paid"`
+	PayCurrency  string     `json:"pay_currency"`
+	PurchaseID   core.ID    `json:"purchase_id"`
+
+	OutcomeAmount   core.Float `json:"outcome_amount"`
+	OutcomeCurrency string     `json:"outcome_currency"`
 
-	OutcomeAmount   float64 `json:"outcome_amount"`
-	OutcomeCurrency string  `json:"outcome_currency"`
+	Fee *PaymentFee `json:"fee,omitempty"`
 
 	PayoutHash *string `json:"payout_hash"`
 	PayinHash  *string `json:"payin_hash"`
 
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-
-	Type                   string  `json:"type"`
-	AmountReceived         float64 `json:"amount_received"`
-	BurningPercent         int     `json:"burning_percent"`
-	ExpirationEstimateDate string  `json:"expiration_estimate_date,omitempty"`
-	Network                string  `json:"network,omitempty"`
-	NetworkPrecision       int     `json:"network_precision,omitempty"`
-	SmartContract          string  `json:"smart_contract,omitempty"`
-	TimeLimit              string  `json:"time_limit,omitempty"`
+	CreatedAt core.Time `json:"created_at"`
+	UpdatedAt core.Time `json:"updated_at"`
+
+	Type                   string     `json:"type"`
+	AmountReceived         core.Float `json:"amount_received"`
+	BurningPercent         int        `json:"burning_percent"`
+	ExpirationEstimateDate core.Time  `json:"expiration_estimate_date,omitempty"`
+	Network                string     `json:"network,omitempty"`
+	NetworkPrecision       int        `json:"network_precision,omitempty"`
+	SmartContract          string     `json:"smart_contract,omitempty"`
+	TimeLimit              string     `json:"time_limit,omitempty"`
+
+	// Extra holds the fields unknown to the library, encoded along with the others
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps the unknown fields in Extra
+func (p *Payment) UnmarshalJSON(data []byte) error {
+	type payment Payment
+	extra, err := core.DecodeExtra(data, (*payment)(p))
+	if err != nil {
+		return err
+	}
+	p.Extra = extra
+	return nil
+}
+
+// MarshalJSON encodes Extra along with the known fields
+func (p Payment) MarshalJSON() ([]byte, error) {
+	type payment Payment
+	return core.EncodeExtra(payment(p), p.Extra)
 }
 
 // New creates a payment
-func New(pa *PaymentArgs) (*Payment[string], error) {
+func New(pa *PaymentArgs) (*Payment, error) {
 	if pa == nil {
 		return nil, errors.New("nil payment args")
 	}
@@ -93,7 +131,7 @@ func New(pa *PaymentArgs) (*Payment[string], error) {
 		return nil, eris.Wrap(err, "payment args")
 	}
 
-	p := &Payment[string]{}
+	p := &Payment{}
 
 	par := &core.SendParams{
 		RouteName: "payment-create",
@@ -121,7 +159,7 @@ type InvoicePaymentArgs struct {
 }
 
 // NewFromInvoice creates a payment from an existing invoice. ID is the invoice's identifier.
-func NewFromInvoice(ipa *InvoicePaymentArgs) (*Payment[string], error) {
+func NewFromInvoice(ipa *InvoicePaymentArgs) (*Payment, error) {
 	if ipa == nil {
 		return nil, errors.New("nil invoice payment args")
 	}
@@ -131,7 +169,7 @@ func NewFromInvoice(ipa *InvoicePaymentArgs) (*Payment[string], error) {
 		return nil, eris.Wrap(err, "payment from invoice args")
 	}
 
-	p := &Payment[string]{}
+	p := &Payment{}
 
 	par := &core.SendParams{
 		RouteName: "invoice-payment",
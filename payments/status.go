@@ -1,28 +1,50 @@
 package payments
 
 import (
+	"encoding/json"
+
 	"github.com/CIDgravity/go-nowpayments/core"
 	"github.com/rotisserie/eris"
 )
 
 // PaymentStatus is the actual information about a payment
 type PaymentStatus struct {
-	ID             int64   `json:"payment_id"`
-	InvoiceID      int64   `json:"invoice_id"`
-	Status         string  `json:"payment_status"`
-	PayAddress     string  `json:"pay_address"`
-	PayinExtraID   string  `json:"payin_extra_id"`
-	PriceAmount    float64 `json:"price_amount"`
-	PriceCurrency  string  `json:"price_currency"`
-	PayAmount      float64 `json:"pay_amount"`
-	ActuallyPaid   float64 `json:"actually_paid"`
-	PayCurrency    string  `json:"pay_currency"`
-	OrderID        string  `json:"order_id"`
-	PurchaseID     int64   `json:"purchase_id"`
-	CreatedAt      string  `json:"created_at"`
-	UpdatedAt      string  `json:"updated_at"`
-	BurningPurcent string  `json:"burning_percent"`
-	Type           string  `json:"type"`
+	ID             core.ID    `json:"payment_id"`
+	InvoiceID      core.ID    `json:"invoice_id"`
+	Status         string     `json:"payment_status"`
+	PayAddress     string     `json:"pay_address"`
+	PayinExtraID   string     `json:"payin_extra_id"`
+	PriceAmount    core.Float `json:"price_amount"`
+	PriceCurrency  string     `json:"price_currency"`
+	PayAmount      core.Float `json:"pay_amount"`
+	ActuallyPaid   core.Float `json:"actually_paid"`
+	PayCurrency    string     `json:"pay_currency"`
+	OrderID        string     `json:"order_id"`
+	PurchaseID     core.ID    `json:"purchase_id"`
+	CreatedAt      core.Time  `json:"created_at"`
+	UpdatedAt      core.Time  `json:"updated_at"`
+	BurningPurcent string     `json:"burning_percent"`
+	Type           string     `json:"type"`
+
+	// Extra holds the fields unknown to the library, encoded along with the others
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps the unknown fields in Extra
+func (st *PaymentStatus) UnmarshalJSON(data []byte) error {
+	type paymentStatus PaymentStatus
+	extra, err := core.DecodeExtra(data, (*paymentStatus)(st))
+	if err != nil {
+		return err
+	}
+	st.Extra = extra
+	return nil
+}
+
+// MarshalJSON encodes Extra along with the known fields
+func (st PaymentStatus) MarshalJSON() ([]byte, error) {
+	type paymentStatus PaymentStatus
+	return core.EncodeExtra(paymentStatus(st), st.Extra)
 }
 
 // Status gets the actual information about the payment. You need to provide the payment ID
@@ -45,7 +45,7 @@ func TestStatus(t *testing.T) {
 			func(c *mocks.HTTPClient, s *PaymentStatus, err error) {
 				assert.NoError(err)
 				assert.NotNil(s)
-				assert.Equal(10.0, s.PayAmount)
+				assert.Equal(core.Float(10), s.PayAmount)
 				assert.Equal("done", s.Status)
 				c.AssertNumberOfCalls(t, "Do", 2)
 			},
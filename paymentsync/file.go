@@ -0,0 +1,193 @@
+package paymentsync
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/payments"
+	"github.com/rotisserie/eris"
+)
+
+// record is a line of a store file, holding either a payment or the sync cursor
+type record struct {
+	Payment *payments.Payment `json:"payment,omitempty"`
+	Cursor  *time.Time        `json:"cursor,omitempty"`
+}
+
+// FileStore is a Store keeping payments in memory and persisting them to a file of JSON lines
+// Changes are appended to the file, which is compacted when opened
+type FileStore struct {
+	mem *MemoryStore
+
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+// OpenFileStore opens the store persisted to path, creating the file if it doesn't exist
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{mem: NewMemoryStore(), path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, eris.Wrap(err, "file store")
+	}
+	s.f = f
+	return s, nil
+}
+
+// load replays the records of the file, the last record of a payment winning
+func (s *FileStore) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return eris.Wrap(err, "file store")
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+
+		var r record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return eris.Wrapf(err, "file store: %s: line %d", s.path, line)
+		}
+		if r.Payment != nil {
+			if err := s.mem.Put(r.Payment); err != nil {
+				return eris.Wrapf(err, "file store: %s: line %d", s.path, line)
+			}
+		}
+		if r.Cursor != nil {
+			s.mem.SetCursor(*r.Cursor)
+		}
+	}
+	return eris.Wrap(sc.Err(), "file store")
+}
+
+// compact rewrites the file with a single record per payment
+func (s *FileStore) compact() error {
+	ps, _ := s.mem.Query(nil)
+	cursor, _ := s.mem.Cursor()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return eris.Wrap(err, "file store")
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, p := range ps {
+		if err := enc.Encode(record{Payment: p}); err != nil {
+			tmp.Close()
+			return eris.Wrap(err, "file store")
+		}
+	}
+	if !cursor.IsZero() {
+		if err := enc.Encode(record{Cursor: &cursor}); err != nil {
+			tmp.Close()
+			return eris.Wrap(err, "file store")
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return eris.Wrap(err, "file store")
+	}
+	if err := tmp.Close(); err != nil {
+		return eris.Wrap(err, "file store")
+	}
+	return eris.Wrap(os.Rename(tmp.Name(), s.path), "file store")
+}
+
+// append writes records to the file, s.mu being held
+func (s *FileStore) append(rs ...record) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, r := range rs {
+		if err := enc.Encode(r); err != nil {
+			return eris.Wrap(err, "file store")
+		}
+	}
+
+	if s.f == nil {
+		return eris.New("file store: closed")
+	}
+	_, err := s.f.Write(b.Bytes())
+	return eris.Wrap(err, "file store")
+}
+
+// Get returns the payment of an ID, or nil if there is none
+func (s *FileStore) Get(id string) (*payments.Payment, error) {
+	return s.mem.Get(id)
+}
+
+// Put records or replaces payments, by ID
+func (s *FileStore) Put(ps ...*payments.Payment) error {
+	if err := validate(ps); err != nil {
+		return err
+	}
+
+	rs := make([]record, 0, len(ps))
+	for _, p := range ps {
+		rs = append(rs, record{Payment: p})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(rs...); err != nil {
+		return err
+	}
+	return s.mem.Put(ps...)
+}
+
+// Query returns the payments matching q, oldest first
+func (s *FileStore) Query(q *Query) ([]*payments.Payment, error) {
+	return s.mem.Query(q)
+}
+
+// Cursor returns the update time reached by the last sync, zero if none
+func (s *FileStore) Cursor() (time.Time, error) {
+	return s.mem.Cursor()
+}
+
+// SetCursor records the update time reached by a sync
+func (s *FileStore) SetCursor(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(record{Cursor: &t}); err != nil {
+		return err
+	}
+	return s.mem.SetCursor(t)
+}
+
+// Close closes the file, the store can't be used afterwards
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return eris.Wrap(err, "file store")
+}
@@ -0,0 +1,166 @@
+// Package paymentsync mirrors the payments of the account into a local store, incrementally by update time and
+// from the IPN notifications, so that they can be queried without calling the API
+package paymentsync
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/payments"
+	"github.com/rotisserie/eris"
+)
+
+// Store persists the mirrored payments
+// Implementations must be safe for concurrent use
+type Store interface {
+	// Get returns the payment of an ID, or nil if there is none
+	Get(id string) (*payments.Payment, error)
+	// Put records or replaces payments, by ID
+	Put(ps ...*payments.Payment) error
+	// Query returns the payments matching q, oldest first
+	Query(q *Query) ([]*payments.Payment, error)
+	// Cursor returns the update time reached by the last sync, zero if none
+	Cursor() (time.Time, error)
+	// SetCursor records the update time reached by a sync
+	SetCursor(t time.Time) error
+}
+
+// Query filters the payments of a store, empty fields matching all the payments
+type Query struct {
+	// Status is the payment status, e.g. finished
+	Status string
+	// Currency is the pay, price or outcome currency, case insensitive
+	Currency string
+	OrderID  string
+	// From and To are the creation time range, From being inclusive and To exclusive
+	From time.Time
+	To   time.Time
+	// Limit is the maximum number of payments returned, 0 meaning no limit
+	Limit int
+}
+
+// Match tells if a payment matches the query, a nil query matching all the payments
+func (q *Query) Match(p *payments.Payment) bool {
+	if q == nil {
+		return true
+	}
+	if q.Status != "" && !strings.EqualFold(q.Status, p.Status) {
+		return false
+	}
+	if q.Currency != "" && !strings.EqualFold(q.Currency, p.PayCurrency) &&
+		!strings.EqualFold(q.Currency, p.PriceCurrency) && !strings.EqualFold(q.Currency, p.OutcomeCurrency) {
+		return false
+	}
+	if q.OrderID != "" && q.OrderID != p.OrderID {
+		return false
+	}
+	if !q.From.IsZero() && p.CreatedAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !p.CreatedAt.Before(q.To) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the payments matching the query, oldest first and at most Limit of them
+func (q *Query) Apply(ps []*payments.Payment) []*payments.Payment {
+	r := []*payments.Payment{}
+	for _, p := range ps {
+		if q.Match(p) {
+			r = append(r, p)
+		}
+	}
+
+	sort.SliceStable(r, func(i, j int) bool {
+		if !r[i].CreatedAt.Equal(r[j].CreatedAt.Time) {
+			return r[i].CreatedAt.Before(r[j].CreatedAt.Time)
+		}
+		return r[i].ID < r[j].ID
+	})
+	if q != nil && q.Limit > 0 && len(r) > q.Limit {
+		r = r[:q.Limit]
+	}
+	return r
+}
+
+// MemoryStore is a Store keeping payments in memory
+type MemoryStore struct {
+	mu       sync.RWMutex
+	payments map[string]payments.Payment
+	cursor   time.Time
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{payments: make(map[string]payments.Payment)}
+}
+
+// Get returns the payment of an ID, or nil if there is none
+func (s *MemoryStore) Get(id string) (*payments.Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.payments[id]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+// validate checks payments can be stored
+func validate(ps []*payments.Payment) error {
+	for _, p := range ps {
+		if p == nil || p.ID == "" {
+			return eris.New("nil payment or empty payment ID")
+		}
+	}
+	return nil
+}
+
+// Put records or replaces payments, by ID
+func (s *MemoryStore) Put(ps ...*payments.Payment) error {
+	if err := validate(ps); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range ps {
+		s.payments[p.ID.String()] = *p
+	}
+	return nil
+}
+
+// Query returns the payments matching q, oldest first
+func (s *MemoryStore) Query(q *Query) ([]*payments.Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ps := make([]*payments.Payment, 0, len(s.payments))
+	for _, p := range s.payments {
+		p := p
+		ps = append(ps, &p)
+	}
+	return q.Apply(ps), nil
+}
+
+// Cursor returns the update time reached by the last sync, zero if none
+func (s *MemoryStore) Cursor() (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cursor, nil
+}
+
+// SetCursor records the update time reached by a sync
+func (s *MemoryStore) SetCursor(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cursor = t
+	return nil
+}
@@ -0,0 +1,136 @@
+package paymentsync
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/payments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func at(day int) core.Time {
+	return core.Time{Time: time.Date(2024, 1, day, 10, 0, 0, 0, time.UTC)}
+}
+
+func payment(id, status, currency, orderID string, day int) *payments.Payment {
+	p := &payments.Payment{ID: core.ID(id), Status: status, PayCurrency: currency, CreatedAt: at(day), UpdatedAt: at(day)}
+	p.OrderID = orderID
+	p.PriceCurrency = "usd"
+	return p
+}
+
+func ids(ps []*payments.Payment) []string {
+	r := []string{}
+	for _, p := range ps {
+		r = append(r, p.ID.String())
+	}
+	return r
+}
+
+func testStore(t *testing.T, s Store) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	require.NoError(s.Put(
+		payment("3", "waiting", "btc", "o3", 3),
+		payment("1", "finished", "usdttrc20", "o1", 1),
+		payment("2", "finished", "BTC", "o2", 2),
+	))
+	require.Error(s.Put(nil))
+
+	p, err := s.Get("2")
+	require.NoError(err)
+	require.NotNil(p)
+	assert.Equal("o2", p.OrderID)
+
+	none, err := s.Get("4")
+	assert.NoError(err)
+	assert.Nil(none)
+
+	tests := []struct {
+		name string
+		q    *Query
+		want []string
+	}{
+		{"all", nil, []string{"1", "2", "3"}},
+		{"status", &Query{Status: "FINISHED"}, []string{"1", "2"}},
+		{"pay currency", &Query{Currency: "btc"}, []string{"2", "3"}},
+		{"price currency", &Query{Currency: "usd"}, []string{"1", "2", "3"}},
+		{"order ID", &Query{OrderID: "o3"}, []string{"3"}},
+		{"date range", &Query{From: at(2).Time, To: at(3).Time}, []string{"2"}},
+		{"limit", &Query{Limit: 2}, []string{"1", "2"}},
+		{"none", &Query{Status: "expired"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps, err := s.Query(tt.q)
+			require.NoError(err)
+			assert.Equal(tt.want, ids(ps))
+		})
+	}
+
+	p.Status = "expired"
+	require.NoError(s.Put(p))
+	ps, err := s.Query(&Query{Status: "expired"})
+	require.NoError(err)
+	assert.Equal([]string{"2"}, ids(ps))
+
+	c, err := s.Cursor()
+	require.NoError(err)
+	assert.True(c.IsZero())
+	require.NoError(s.SetCursor(at(5).Time))
+	c, err = s.Cursor()
+	require.NoError(err)
+	assert.True(at(5).Equal(c))
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "payments.jsonl")
+	s, err := OpenFileStore(path)
+	require.NoError(err)
+	testStore(t, s)
+
+	p := payment("4", "finished", "btc", "o4", 4)
+	p.Extra = map[string]json.RawMessage{"new_field": json.RawMessage(`"kept"`)}
+	require.NoError(s.Put(p))
+	require.NoError(s.Close())
+	assert.Error(s.Put(p))
+
+	data, err := os.ReadFile(path)
+	require.NoError(err)
+	assert.Equal(6, strings.Count(string(data), "\n"), "records are appended")
+
+	s, err = OpenFileStore(path)
+	require.NoError(err)
+	defer s.Close()
+
+	ps, err := s.Query(nil)
+	require.NoError(err)
+	assert.Equal([]string{"1", "2", "3", "4"}, ids(ps))
+	assert.Equal("expired", ps[1].Status)
+	assert.Equal(json.RawMessage(`"kept"`), ps[3].Extra["new_field"])
+	c, err := s.Cursor()
+	require.NoError(err)
+	assert.True(at(5).Equal(c))
+
+	data, err = os.ReadFile(path)
+	require.NoError(err)
+	assert.Equal(5, strings.Count(string(data), "\n"), "file is compacted when opened")
+
+	os.WriteFile(path, []byte("{bad\n"), 0o600)
+	_, err = OpenFileStore(path)
+	assert.Error(err)
+}
@@ -0,0 +1,195 @@
+package paymentsync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/CIDgravity/go-nowpayments/ipn"
+	"github.com/CIDgravity/go-nowpayments/payments"
+	"github.com/rotisserie/eris"
+)
+
+const (
+	defaultPageSize = 100
+	defaultOverlap  = time.Minute
+)
+
+// statusRanks orders the payment statuses, a payment never going back to a lower rank
+// A partially paid payment is only finished by another payment, and the final statuses may follow each other,
+// e.g. a finished payment being refunded
+var statusRanks = map[string]int{
+	"waiting":        1,
+	"confirming":     2,
+	"confirmed":      3,
+	"sending":        4,
+	"partially_paid": 5,
+	"finished":       6,
+	"failed":         6,
+	"refunded":       6,
+	"expired":        6,
+}
+
+// Syncer mirrors the payments of the account into a store
+type Syncer struct {
+	// PageSize is the number of payments fetched per call (default 100)
+	PageSize int
+	// Overlap is how long before the last sync cursor payments are fetched again, to catch the updates made
+	// while the last sync was running (default 1 minute)
+	Overlap time.Duration
+
+	store Store
+
+	// syncMu serializes the syncs, mu the read-modify-write of the stored payments
+	syncMu sync.Mutex
+	mu     sync.Mutex
+}
+
+// New returns a syncer mirroring the payments into s
+func New(s Store) *Syncer {
+	return &Syncer{store: s}
+}
+
+// Sync fetches the payments updated since the last sync, most recently updated first, and stores those more
+// recent than their stored copy. The first sync fetches all the payments. It returns the number of payments stored
+// JWT is required for this request
+func (s *Syncer) Sync() (int, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	limit := s.PageSize
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	overlap := s.Overlap
+	if overlap <= 0 {
+		overlap = defaultOverlap
+	}
+
+	cursor, err := s.store.Cursor()
+	if err != nil {
+		return 0, eris.Wrap(err, "sync: cursor")
+	}
+	since := cursor
+	if !since.IsZero() {
+		since = since.Add(-overlap)
+	}
+
+	n := 0
+	newest := cursor
+	for page := 0; ; page++ {
+		ps, err := payments.List(&payments.ListOption{
+			Limit:   limit,
+			Page:    page,
+			SortBy:  "updated_at",
+			OrderBy: "desc",
+		})
+		if err != nil {
+			return n, eris.Wrap(err, "sync")
+		}
+
+		done := len(ps) < limit
+		fresh := []*payments.Payment{}
+		for _, p := range ps {
+			if p == nil || p.ID == "" {
+				continue
+			}
+			if !since.IsZero() && p.UpdatedAt.Before(since) {
+				// The following payments have been updated before too
+				done = true
+				break
+			}
+			if p.UpdatedAt.After(newest) {
+				newest = p.UpdatedAt.Time
+			}
+			fresh = append(fresh, p)
+		}
+
+		stored, err := s.putNewer(fresh)
+		n += stored
+		if err != nil {
+			return n, eris.Wrap(err, "sync: put")
+		}
+		if done {
+			break
+		}
+	}
+
+	if newest.After(cursor) {
+		if err := s.store.SetCursor(newest); err != nil {
+			return n, eris.Wrap(err, "sync: cursor")
+		}
+	}
+	return n, nil
+}
+
+// putNewer stores the payments updated after their stored copy, returning how many were stored
+func (s *Syncer) putNewer(ps []*payments.Payment) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newer := []*payments.Payment{}
+	for _, p := range ps {
+		old, err := s.store.Get(p.ID.String())
+		if err != nil {
+			return 0, err
+		}
+		if old == nil || p.UpdatedAt.After(old.UpdatedAt.Time) {
+			newer = append(newer, p)
+		}
+	}
+	if len(newer) == 0 {
+		return 0, nil
+	}
+	return len(newer), s.store.Put(newer...)
+}
+
+// ApplyIPN updates the stored payment with the status and amounts of an IPN notification, creating it when
+// unknown. Its update time is kept, so that the next sync still replaces it with a more recent copy
+// A notification delivered late, whose status precedes the stored one (e.g. confirming after finished), is ignored
+// It can be used as the callback of an ipn.Handler
+func (s *Syncer) ApplyIPN(_ context.Context, n *ipn.IPNPaymentStatus) error {
+	if n == nil {
+		return eris.New("sync: nil IPN notification")
+	}
+	if n.PaymentID == "" {
+		return eris.New("sync: IPN notification without payment ID")
+	}
+	id := n.PaymentID.String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.store.Get(id)
+	if err != nil {
+		return eris.Wrap(err, "sync: get")
+	}
+	if p == nil {
+		p = &payments.Payment{ID: n.PaymentID, InvoiceID: n.InvoiceID, PurchaseID: n.PurchaseID}
+		p.PriceAmount = n.PriceAmount.Float64()
+		p.PriceCurrency = n.PriceCurrency
+		p.OrderID = n.OrderID
+		p.OrderDescription = n.OrderDescription
+		p.PayAddress = n.PayAddress
+		p.PayCurrency = n.PayCurrency
+	} else if r := statusRanks[n.PaymentStatus]; r > 0 && r < statusRanks[p.Status] {
+		return nil
+	}
+
+	p.Status = n.PaymentStatus
+	p.PayAmount = n.PayAmount
+	p.ActuallyPaid = n.ActuallyPaid
+	p.OutcomeAmount = n.OutcomeAmount
+	if n.OutcomeCurrency != "" {
+		p.OutcomeCurrency = n.OutcomeCurrency
+	}
+	if n.Fee.Currency != "" {
+		p.Fee = &payments.PaymentFee{
+			Currency:      n.Fee.Currency,
+			DepositFee:    n.Fee.DepositFee,
+			ServiceFee:    n.Fee.ServiceFee,
+			WithdrawalFee: n.Fee.WithdrawalFee,
+		}
+	}
+	return eris.Wrap(s.store.Put(p), "sync: put")
+}
@@ -0,0 +1,137 @@
+package paymentsync
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CIDgravity/go-nowpayments/config"
+	"github.com/CIDgravity/go-nowpayments/core"
+	"github.com/CIDgravity/go-nowpayments/ipn"
+	"github.com/CIDgravity/go-nowpayments/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type rc struct {
+	*strings.Reader
+}
+
+func (*rc) Close() error {
+	return nil
+}
+
+func newResponseOK(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       &rc{strings.NewReader(body)},
+	}
+}
+
+func listed(id, status string, day int) string {
+	return fmt.Sprintf(`{"payment_id":%s,"payment_status":%q,"pay_currency":"btc","created_at":"2024-01-01T10:00:00.000Z","updated_at":"2024-01-%02dT10:00:00.000Z"}`, id, status, day)
+}
+
+func TestSyncer(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	require.NoError(config.Load(&config.Credentials{Login: "l", Password: "p", APIKey: "key", Server: "http://some.tld"}))
+	c := mocks.NewHTTPClient(t)
+	core.UseClient(c)
+
+	// Pages of payments returned by each sync, most recently updated first
+	syncs := [][]string{
+		{
+			"[" + listed("1", "confirming", 5) + "," + listed("2", "waiting", 4) + "]",
+			"[" + listed("3", "waiting", 3) + "]",
+		},
+		{
+			"[" + listed("3", "finished", 6) + "," + listed("1", "confirming", 5) + "]",
+			"[" + listed("2", "waiting", 4) + "]",
+		},
+	}
+	round := 0
+	c.EXPECT().Do(mock.Anything).Call.Return(
+		func(req *http.Request) *http.Response {
+			switch req.URL.Path {
+			case "/v1/auth":
+				return newResponseOK(`{"token":"tok"}`)
+			case "/v1/payment/":
+				q := req.URL.Query()
+				assert.Equal("updated_at", q.Get("sortBy"))
+				assert.Equal("desc", q.Get("orderBy"))
+				page := 0
+				fmt.Sscan(q.Get("page"), &page)
+				return newResponseOK(`{"data":` + syncs[round][page] + `}`)
+			default:
+				t.Fatalf("unexpected route call %q", req.URL.Path)
+			}
+			return nil
+		}, nil)
+
+	s := NewMemoryStore()
+	sy := New(s)
+	sy.PageSize = 2
+
+	n, err := sy.Sync()
+	require.NoError(err)
+	assert.Equal(3, n)
+	cursor, err := s.Cursor()
+	require.NoError(err)
+	assert.True(at(5).Equal(cursor))
+
+	require.NoError(sy.ApplyIPN(context.Background(), &ipn.IPNPaymentStatus{
+		PaymentID:     "1",
+		PaymentStatus: "finished",
+		ActuallyPaid:  0.5,
+		PayCurrency:   "btc",
+		Fee:           ipn.IPNPaymentFees{Currency: "btc", ServiceFee: 0.001},
+	}))
+	require.NoError(sy.ApplyIPN(context.Background(), &ipn.IPNPaymentStatus{
+		PaymentID:     "9",
+		PaymentStatus: "waiting",
+		PayCurrency:   "eth",
+		OrderID:       "o9",
+	}))
+	assert.Error(sy.ApplyIPN(context.Background(), &ipn.IPNPaymentStatus{}))
+	assert.Error(sy.ApplyIPN(context.Background(), nil))
+	require.NoError(sy.ApplyIPN(context.Background(), &ipn.IPNPaymentStatus{
+		PaymentID:     "1",
+		PaymentStatus: "confirming",
+		ActuallyPaid:  0.2,
+		PayCurrency:   "btc",
+	}), "late IPN acknowledged")
+
+	round++
+	n, err = sy.Sync()
+	require.NoError(err)
+	assert.Equal(1, n, "only payment 3 has been updated since the last sync")
+	cursor, err = s.Cursor()
+	require.NoError(err)
+	assert.True(at(6).Equal(cursor))
+
+	p, err := s.Get("1")
+	require.NoError(err)
+	assert.Equal("finished", p.Status, "IPN update is not replaced by a stale copy nor a late IPN")
+	assert.Equal(0.5, p.ActuallyPaid.Float64())
+	assert.Equal(0.001, p.Fee.Total())
+
+	p, err = s.Get("3")
+	require.NoError(err)
+	assert.Equal("finished", p.Status)
+
+	ps, err := s.Query(&Query{OrderID: "o9"})
+	require.NoError(err)
+	if assert.Len(ps, 1) {
+		assert.Equal("waiting", ps[0].Status)
+		assert.Equal("eth", ps[0].PayCurrency)
+	}
+
+	ps, err = s.Query(&Query{Status: "finished", Currency: "btc"})
+	require.NoError(err)
+	assert.Equal([]string{"1", "3"}, ids(ps))
+}
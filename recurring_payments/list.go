@@ -37,10 +37,10 @@ func List(o *ListOption) ([]*RecurringPayment, error) {
 		}
 	}
 
-	rpl := &core.V2ResponseFormat[[]*RecurringPayment]{}
+	rpl := make([]*RecurringPayment, 0)
 	par := &core.SendParams{
 		RouteName: "recurring-payment-list",
-		Into:      rpl,
+		Into:      &rpl,
 		Values:    u,
 	}
 
@@ -49,5 +49,5 @@ func List(o *ListOption) ([]*RecurringPayment, error) {
 		return nil, err
 	}
 
-	return rpl.Result, nil
+	return rpl, nil
 }
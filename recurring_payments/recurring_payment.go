@@ -4,9 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"strings"
-	"time"
 
-	"github.com/CIDgravity/go-nowpayments/config"
 	"github.com/CIDgravity/go-nowpayments/core"
 	"github.com/rotisserie/eris"
 )
@@ -23,10 +21,30 @@ type RecurringPayment struct {
 	SubscriptionPlanID string     `json:"subscription_plan_id"`
 	IsActive           bool       `json:"is_active"`
 	Status             string     `json:"status"`
-	ExpireDate         string     `json:"expire_date"`
+	ExpireDate         core.Time  `json:"expire_date"`
 	Subscriber         Subscriber `json:"subscriber"`
-	CreatedAt          time.Time  `json:"created_at"`
-	UpdatedAt          time.Time  `json:"updated_at"`
+	CreatedAt          core.Time  `json:"created_at"`
+	UpdatedAt          core.Time  `json:"updated_at"`
+
+	// Extra holds the fields unknown to the library, encoded along with the others
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps the unknown fields in Extra
+func (r *RecurringPayment) UnmarshalJSON(data []byte) error {
+	type recurringPayment RecurringPayment
+	extra, err := core.DecodeExtra(data, (*recurringPayment)(r))
+	if err != nil {
+		return err
+	}
+	r.Extra = extra
+	return nil
+}
+
+// MarshalJSON encodes Extra along with the known fields
+func (r RecurringPayment) MarshalJSON() ([]byte, error) {
+	type recurringPayment RecurringPayment
+	return core.EncodeExtra(recurringPayment(r), r.Extra)
 }
 
 // DeleteReccurringPayment handle status when deleting recurring payment
@@ -53,17 +71,17 @@ func New(ru *RecurringPaymentArgs) (*RecurringPayment, error) {
 		return nil, eris.Wrap(err, "recurring payment args")
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "recurring payment")
 	}
 
 	// Inconsistency on their side: single sub partner ID is allowed, but response is an array
-	// will return only the first element of array
-	rcu := &core.V2ResponseFormat[[]*RecurringPayment]{}
+	// of a single element, unwrapped when decoded
+	rcu := &RecurringPayment{}
 	par := &core.SendParams{
 		RouteName: "recurring-payment-create",
-		Into:      &rcu,
+		Into:      rcu,
 		JWTToken:  tok,
 		Body:      strings.NewReader(string(d)),
 	}
@@ -73,7 +91,7 @@ func New(ru *RecurringPaymentArgs) (*RecurringPayment, error) {
 		return nil, err
 	}
 
-	return rcu.Result[0], nil
+	return rcu, nil
 }
 
 // Get return a single reccuring payment via it's ID
@@ -82,11 +100,11 @@ func Get(recurringPaymentID string) (*RecurringPayment, error) {
 		return nil, eris.New("empty recurring payment ID")
 	}
 
-	rp := &core.V2ResponseFormat[*RecurringPayment]{}
+	rp := &RecurringPayment{}
 	par := &core.SendParams{
 		RouteName: "recurring-payment-single",
 		Path:      recurringPaymentID,
-		Into:      &rp,
+		Into:      rp,
 	}
 
 	err := core.HTTPSend(par)
@@ -94,7 +112,7 @@ func Get(recurringPaymentID string) (*RecurringPayment, error) {
 		return nil, err
 	}
 
-	return rp.Result, nil
+	return rp, nil
 }
 
 // Delete remove a recurring payment via it's ID
@@ -104,12 +122,12 @@ func Delete(recurringPaymentID string) (*string, error) {
 		return nil, eris.New("empty recurring payment ID")
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "recurring payment")
 	}
 
-	de := &core.V2ResponseFormat[*string]{}
+	var de *string
 	par := &core.SendParams{
 		RouteName: "recurring-payment-delete",
 		Path:      recurringPaymentID,
@@ -122,5 +140,5 @@ func Delete(recurringPaymentID string) (*string, error) {
 		return nil, err
 	}
 
-	return de.Result, nil
+	return de, nil
 }
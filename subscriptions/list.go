@@ -26,10 +26,10 @@ func List(o *ListOption) ([]*Subscription, error) {
 		}
 	}
 
-	pl := &core.V2ResponseFormat[[]*Subscription]{}
+	pl := make([]*Subscription, 0)
 	par := &core.SendParams{
 		RouteName: "subscription-list",
-		Into:      pl,
+		Into:      &pl,
 		Values:    u,
 	}
 
@@ -38,5 +38,5 @@ func List(o *ListOption) ([]*Subscription, error) {
 		return nil, err
 	}
 
-	return pl.Result, nil
+	return pl, nil
 }
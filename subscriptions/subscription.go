@@ -4,9 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"strings"
-	"time"
 
-	"github.com/CIDgravity/go-nowpayments/config"
 	"github.com/CIDgravity/go-nowpayments/core"
 	recurringPayment "github.com/CIDgravity/go-nowpayments/recurring_payments"
 	"github.com/rotisserie/eris"
@@ -37,8 +35,28 @@ type Subscription struct {
 	PartiallyPaidURL string    `json:"partially_paid_url,omitempty"`
 	Amount           float64   `json:"amount"`
 	Currency         string    `json:"currency"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	CreatedAt        core.Time `json:"created_at"`
+	UpdatedAt        core.Time `json:"updated_at"`
+
+	// Extra holds the fields unknown to the library, encoded along with the others
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps the unknown fields in Extra
+func (s *Subscription) UnmarshalJSON(data []byte) error {
+	type subscription Subscription
+	extra, err := core.DecodeExtra(data, (*subscription)(s))
+	if err != nil {
+		return err
+	}
+	s.Extra = extra
+	return nil
+}
+
+// MarshalJSON encodes Extra along with the known fields
+func (s Subscription) MarshalJSON() ([]byte, error) {
+	type subscription Subscription
+	return core.EncodeExtra(subscription(s), s.Extra)
 }
 
 // New create a subscription plan
@@ -53,15 +71,15 @@ func New(su *SubscriptionArgs) (*Subscription, error) {
 		return nil, eris.Wrap(err, "subscription args")
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "subscription")
 	}
 
-	s := &core.V2ResponseFormat[*Subscription]{}
+	s := &Subscription{}
 	par := &core.SendParams{
 		RouteName: "subscription-create",
-		Into:      &s,
+		Into:      s,
 		Body:      strings.NewReader(string(d)),
 		JWTToken:  tok,
 	}
@@ -71,7 +89,7 @@ func New(su *SubscriptionArgs) (*Subscription, error) {
 		return nil, err
 	}
 
-	return s.Result, nil
+	return s, nil
 }
 
 // NewWithEmail create an email subscription with specific plan ID
@@ -86,17 +104,17 @@ func NewWithEmail(su *EmailSubscriptionArgs) (*recurringPayment.RecurringPayment
 		return nil, eris.Wrap(err, "subscription email args")
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "subscription")
 	}
 
 	// Inconsistency on their side: this request allow only one e-mail, but respond with an array of RecurringPayment
-	// So will return only the first element of array
-	s := &core.V2ResponseFormat[[]*recurringPayment.RecurringPayment]{}
+	// of a single element, unwrapped when decoded
+	s := &recurringPayment.RecurringPayment{}
 	par := &core.SendParams{
 		RouteName: "subscription-create-email",
-		Into:      &s,
+		Into:      s,
 		JWTToken:  tok,
 		Body:      strings.NewReader(string(d)),
 	}
@@ -106,7 +124,7 @@ func NewWithEmail(su *EmailSubscriptionArgs) (*recurringPayment.RecurringPayment
 		return nil, err
 	}
 
-	return s.Result[0], nil
+	return s, nil
 }
 
 // Update update a subscription plan
@@ -125,15 +143,15 @@ func Update(subscriptionPlanID string, su *SubscriptionArgs) (*Subscription, err
 		return nil, eris.Wrap(err, "subscription args")
 	}
 
-	tok, err := core.Authenticate(config.Login(), config.Password())
+	tok, err := core.AuthenticateFromConfig()
 	if err != nil {
 		return nil, eris.Wrap(err, "subscription")
 	}
 
-	s := &core.V2ResponseFormat[*Subscription]{}
+	s := &Subscription{}
 	par := &core.SendParams{
 		RouteName: "subscription-update",
-		Into:      &s,
+		Into:      s,
 		JWTToken:  tok,
 		Path:      subscriptionPlanID,
 		Body:      strings.NewReader(string(d)),
@@ -144,7 +162,7 @@ func Update(subscriptionPlanID string, su *SubscriptionArgs) (*Subscription, err
 		return nil, err
 	}
 
-	return s.Result, nil
+	return s, nil
 }
 
 // Get return a single subscription plan by ID
@@ -153,11 +171,11 @@ func Get(subscriptionPlanID string) (*Subscription, error) {
 		return nil, eris.New("empty subscription plan ID")
 	}
 
-	st := &core.V2ResponseFormat[*Subscription]{}
+	st := &Subscription{}
 	par := &core.SendParams{
 		RouteName: "subscription-single",
 		Path:      subscriptionPlanID,
-		Into:      &st,
+		Into:      st,
 	}
 
 	err := core.HTTPSend(par)
@@ -165,5 +183,5 @@ func Get(subscriptionPlanID string) (*Subscription, error) {
 		return nil, err
 	}
 
-	return st.Result, nil
+	return st, nil
 }